@@ -7,5 +7,7 @@ require (
 	github.com/onsi/ginkgo v1.15.2
 	github.com/onsi/gomega v1.11.0
 	github.com/spf13/cobra v1.1.3
+	golang.org/x/sys v0.0.0-20210112080510-489259a85091
+	golang.org/x/text v0.3.3
 	gopkg.in/yaml.v2 v2.4.0
 )
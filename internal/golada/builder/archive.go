@@ -0,0 +1,164 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package builder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/homeport/pina-golada/pkg/compressor"
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+const (
+	// SourceFiles reads the asset from the files and directories on disk, which is the default
+	SourceFiles = "files"
+
+	// SourceArchive reads the asset from an archive, e.g. a .tar.gz built by a release pipeline
+	SourceArchive = "archive"
+)
+
+// loadArchive reads the archive of the method annotation and validates it by decompressing it with the compressor
+// that matches its file extension. If the annotation names a Root, that directory of the archive becomes the root
+// of the assets. The archive is returned to be embedded as-is, unless it is re-rooted or the annotation requests
+// a different compressor, a level or options. Then the archive is nil and the directory is compressed again, by
+// default with the compressor of the archive.
+func (b Builder) loadArchive(annotation *PinaGoladaMethod, methodName string) (files.Directory, []byte, error) {
+	methodIdentifier := b.target.Name.Name + "#" + methodName
+
+	if info, e := os.Stat(annotation.Asset); e == nil && info.IsDir() {
+		return nil, nil, fmt.Errorf("asset %s of %s is a directory, expected an archive", annotation.Asset,
+			methodIdentifier)
+	}
+
+	content, e := ioutil.ReadFile(annotation.Asset)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	archiveID, e := archiveCompressor(annotation.Asset, annotation.Compressor)
+	if e != nil {
+		return nil, nil, fmt.Errorf("%w of %s", e, methodIdentifier)
+	}
+
+	directory, e := compressor.DefaultRegistry.Find(archiveID).Decompress(context.Background(), bytes.NewReader(content))
+	if e == nil {
+		_, e = compressor.TreeChecksum(directory) // Read every file, as some compressors decompress lazily
+	}
+
+	if e != nil {
+		return nil, nil, fmt.Errorf("invalid %s archive %s of %s: %w", archiveID, annotation.Asset, methodIdentifier, e)
+	}
+
+	b.logger.Debug("Gray{Debug➤ Validated} LimeGreen{%s} Gray{archive} LimeGreen{%s} Gray{for method} LimeGreen{%s}",
+		archiveID, annotation.Asset, methodIdentifier)
+
+	if len(annotation.Root) > 0 {
+		var root files.Directory
+		if rootPath := paths.Of(annotation.Root); rootPath.Valid() {
+			root = directory.Directory(rootPath)
+		}
+
+		if root == nil {
+			return nil, nil, fmt.Errorf("archive %s of %s has no directory %s", annotation.Asset, methodIdentifier,
+				annotation.Root)
+		}
+
+		defaultCompressor(annotation, archiveID)
+		return root.AsRoot(), nil, nil
+	}
+
+	if annotation.Level != 0 || len(annotation.Options) > 0 {
+		defaultCompressor(annotation, archiveID)
+		return directory, nil, nil
+	}
+
+	if len(annotation.Compressor) > 0 {
+		requested, e := compressor.DefaultRegistry.Describe(annotation.Compressor)
+		if e != nil || requested.ID != archiveID {
+			return directory, nil, nil
+		}
+	}
+
+	annotation.Compressor = archiveID
+	return directory, content, nil
+}
+
+// defaultCompressor compresses the assets with the compressor of the archive unless another one is requested
+func defaultCompressor(annotation *PinaGoladaMethod, archiveID string) {
+	if len(annotation.Compressor) == 0 {
+		annotation.Compressor = archiveID
+	}
+}
+
+// archiveCompressor returns the canonical id of the compressor whose file extension matches the name of the
+// archive. The requested compressor is preferred, otherwise the longest matching extension wins, followed by the
+// shortest id. Pipelines of all archivers and codecs are considered, e.g. tar+identity for plain .tar files.
+func archiveCompressor(name string, requested string) (string, error) {
+	name = strings.ToLower(filepath.Base(name))
+	matches := func(metadata compressor.Metadata) bool {
+		return len(metadata.Extension) > 0 && strings.HasSuffix(name, strings.ToLower(metadata.Extension))
+	}
+
+	if len(requested) > 0 {
+		if metadata, e := compressor.DefaultRegistry.Describe(requested); e == nil && matches(metadata) {
+			return metadata.ID, nil
+		}
+	}
+
+	var ids []string
+	for _, metadata := range compressor.DefaultRegistry.List() {
+		ids = append(ids, metadata.ID)
+	}
+
+	for _, archiver := range compressor.DefaultRegistry.Archivers() {
+		for _, codec := range compressor.DefaultRegistry.Codecs() {
+			ids = append(ids, archiver+"+"+codec)
+		}
+	}
+
+	var best compressor.Metadata
+	for _, id := range ids {
+		metadata, e := compressor.DefaultRegistry.Describe(id)
+		if e != nil || !matches(metadata) {
+			continue
+		}
+
+		switch {
+		case len(metadata.Extension) > len(best.Extension),
+			len(metadata.Extension) == len(best.Extension) && len(metadata.ID) < len(best.ID),
+			len(metadata.Extension) == len(best.Extension) && len(metadata.ID) == len(best.ID) && metadata.ID < best.ID:
+			best = metadata
+		}
+	}
+
+	if len(best.ID) == 0 {
+		return "", fmt.Errorf("no compressor matches the file extension of archive %s", name)
+	}
+
+	return best.ID, nil
+}
@@ -24,6 +24,7 @@ package builder
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -51,11 +52,43 @@ var (
 	// InternalDecompressMethod defines the method that is generated by pina-golada to decompress
 	// a given hex string. It cannot be defined by an interface
 	InternalDecompressMethod = "requestAssetByPath"
+
+	// InternalDecryptMethod defines the method that is generated by pina-golada to decrypt and decompress
+	// a given hex string of encrypted assets. It cannot be defined by an interface
+	InternalDecryptMethod = "requestEncryptedAssetByPath"
+
+	// InternalVerifyMethod defines the method that is generated by pina-golada to verify the signature of
+	// a given hex string before it is decompressed. It cannot be defined by an interface
+	InternalVerifyMethod = "requestSignedAssetByPath"
 )
 
-// PinaGoladaInterface is the struct used for the pina golada interface annotation
+const (
+	// ValidationWarn logs every asset name that is not portable to the target platforms
+	ValidationWarn = "warn"
+
+	// ValidationFail fails the generation if an asset name is not portable to the target platforms
+	ValidationFail = "fail"
+
+	// ValidationOff disables the portability check of asset names
+	ValidationOff = "off"
+)
+
+// PinaGoladaInterface is the struct used for the pina golada interface annotation.
+// Platforms lists the operating systems the asset names have to be portable to, all by default.
+// Validation defines whether a non portable name is a warning, which is the default, an error or ignored.
+// KeyFile or KeyEnvironment name the source of the key for encrypted assets, DefaultKeyEnvironment by default.
+// SigningKeyFile or SigningKeyEnvironment name the source of the ed25519 key that signs all assets, which are
+// not signed if neither is set.
+// SharedDictionary shares one preset dictionary across all methods using the DictionaryCompressor.
 type PinaGoladaInterface struct {
-	Injector string `yaml:"injector"`
+	Injector              string `yaml:"injector"`
+	Platforms             string `yaml:"platforms"`
+	Validation            string `yaml:"validation"`
+	KeyEnvironment        string `yaml:"keyenv"`
+	KeyFile               string `yaml:"keyfile"`
+	SigningKeyEnvironment string `yaml:"signingkeyenv"`
+	SigningKeyFile        string `yaml:"signingkeyfile"`
+	SharedDictionary      bool   `yaml:"shareddictionary"`
 }
 
 // GetIdentifier returns the identifier of the interface
@@ -63,11 +96,25 @@ func (PinaGoladaInterface) GetIdentifier() string {
 	return "pgl"
 }
 
-// PinaGoladaMethod is the struct used for the pina golada interface annotation
+// PinaGoladaMethod is the struct used for the pina golada interface annotation.
+// Level and Options configure the compressor, see compressor.ParseOptions for the format of the options.
+// Candidates and Selection configure the choice of the AutoCompressor, see Builder.compress.
+// Encrypted seals the compressed assets using AES-256-GCM, see compressor.Seal.
+// Source defines whether the asset is read from the files on disk or from an archive, see SourceArchive. Root
+// names the directory of an archive that becomes the root of the assets. Lookup sets the files.LookupMode of the
+// returned directory, see files.ParseLookupMode for its format.
 type PinaGoladaMethod struct {
 	Asset        string `yaml:"asset"`
 	Compressor   string `yaml:"compressor"`
 	AbsolutePath bool   `yaml:"absolute"`
+	Level        int    `yaml:"level"`
+	Options      string `yaml:"options"`
+	Candidates   string `yaml:"candidates"`
+	Selection    string `yaml:"selection"`
+	Encrypted    bool   `yaml:"encrypted"`
+	Source       string `yaml:"source"`
+	Root         string `yaml:"root"`
+	Lookup       string `yaml:"lookup"`
 }
 
 // GetIdentifier returns the identifier of the interface
@@ -75,6 +122,16 @@ func (PinaGoladaMethod) GetIdentifier() string {
 	return "pgl"
 }
 
+// methodAssets are the loaded assets of a method of the interface
+type methodAssets struct {
+	methodName string
+	annotation *PinaGoladaMethod
+	directory  files.Directory
+	isDir      bool
+	archive    []byte
+	lookup     files.LookupMode
+}
+
 // Builder is able to build a file
 type Builder struct {
 	target              *inspector.AstInterface
@@ -104,6 +161,7 @@ func (b Builder) BuildFile() (by []byte, err error) {
 
 	goGenerator.Struct(structName, func(s generator.StructGenerator) {})
 	goGenerator.Import("bytes")
+	goGenerator.Import("context")
 	goGenerator.Import("encoding/hex")
 	goGenerator.Import("fmt")
 	goGenerator.Import("github.com/homeport/pina-golada/pkg/compressor")
@@ -112,26 +170,41 @@ func (b Builder) BuildFile() (by []byte, err error) {
 	goGenerator.Method(InternalDecompressMethod, func(method generator.MethodGenerator) {
 		// As we generate a method that uses fmt.Errorf therefore it has to ignore that we don't pass a value
 		// to the Sprintf method, therefore we pass nil
-		method.Receiver(receiverType).Parameters("compressorType string", "hexedContent string").
+		method.Receiver(receiverType).
+			Parameters("methodName string", "compressorType string", "hexedContent string", "blobChecksum string",
+				"treeChecksum string").
 			ReturnTypes("files.Directory", "error").
 			Body([]string{
 				fmt.Sprintf("c := compressor.DefaultRegistry.Find(compressorType)"),
 				fmt.Sprintf(`if c == nil{return nil, fmt.Errorf("could not find compressor for %s", compressorType)}`, "%s"),
 				fmt.Sprintf(`decodedBytes , er := hex.DecodeString(hexedContent)`),
-				fmt.Sprintf(`if er != nil {return nil , er}`),
-				fmt.Sprintf(`return c.Decompress(bytes.NewBuffer(decodedBytes))`),
+				fmt.Sprintf(`if er != nil {return nil , compressor.NewIntegrityError("%s", methodName, "invalid hex content: " + er.Error())}`,
+					b.target.Name.Name),
+				fmt.Sprintf(`return compressor.NewChecked(c, "%s", methodName, blobChecksum, treeChecksum).Decompress(context.Background(), bytes.NewReader(decodedBytes))`,
+					b.target.Name.Name),
 			}...)
 	})
 
+	signingKey, e := b.signingKey()
+	if e != nil {
+		return nil, e
+	}
+
+	if signingKey != nil {
+		b.generateVerifyMethod(goGenerator, receiverType, signingKey.Public().(ed25519.PublicKey))
+	}
+
+	var assets []methodAssets
 	for _, method := range b.target.InterfaceReference.Methods.List {
 		if len(method.Names) < 1 {
 			return nil, errors.New("method commented with " + method.Doc.Text() + " has no name")
 		}
 		methodName := method.Names[0].Name
-		if methodName == "requestAssetByPath" {
+		if methodName == InternalDecompressMethod || methodName == InternalDecryptMethod ||
+			methodName == InternalVerifyMethod {
 			return nil, fmt.Errorf("interface %s defined a method with the name %s which is internally used",
 				b.target.Name.Name,
-				InternalDecompressMethod)
+				methodName)
 		}
 
 		methodAnnotation := &PinaGoladaMethod{}
@@ -141,77 +214,180 @@ func (b Builder) BuildFile() (by []byte, err error) {
 		if len(methodAnnotation.Asset) < 1 {
 			return nil, errors.New("no asset path was provided for " + methodName)
 		}
-		if len(methodAnnotation.Compressor) < 1 {
+		isArchive := strings.EqualFold(methodAnnotation.Source, SourceArchive)
+		if len(methodAnnotation.Compressor) < 1 && !isArchive {
 			return nil, errors.New("no compressor was provided for " + methodName)
 		}
 		if !methodAnnotation.AbsolutePath && !strings.HasPrefix(methodAnnotation.Asset, "."+string(filepath.Separator)) {
 			methodAnnotation.Asset = filepath.Join(".", methodAnnotation.Asset)
 		}
 
-		directory := files.NewRootDirectory()
-		topLevelDir := directory // Keep a copy of the top level directory anyway to compress against later
+		lookup := files.ExactLookup
+		if len(methodAnnotation.Lookup) > 0 {
+			if lookup, e = files.ParseLookupMode(methodAnnotation.Lookup); e != nil {
+				return nil, fmt.Errorf("invalid lookup of %s: %w", methodName, e)
+			}
+		}
+
+		topLevelDir := files.NewRootDirectory()
 		isDir := false
+		var archive []byte
+
+		switch {
+		case isArchive:
+			if topLevelDir, archive, e = b.loadArchive(methodAnnotation, methodName); e != nil {
+				return nil, e
+			}
+
+		case len(methodAnnotation.Source) > 0 && !strings.EqualFold(methodAnnotation.Source, SourceFiles):
+			return nil, fmt.Errorf("unknown source %s of %s, expected %s or %s", methodAnnotation.Source,
+				methodName, SourceFiles, SourceArchive)
+
+		case len(methodAnnotation.Root) > 0:
+			return nil, fmt.Errorf("root of %s is only supported for the source %s", methodName, SourceArchive)
 
-		if i, e := os.Stat(methodAnnotation.Asset); e == nil {
-			// Create a sub directory inside if the target asset is a dir.
-			if i.IsDir() {
-				directory = directory.NewDirectory(paths.Of(i.Name()))
-				isDir = true
+		default:
+			if topLevelDir, isDir, e = loadAsset(methodAnnotation.Asset); e != nil {
+				return nil, e
 			}
 		}
 
-		e := files.LoadFromDisk(directory, methodAnnotation.Asset)
-		if e != nil {
+		if err := b.validatePortable(topLevelDir, methodName); err != nil {
+			return nil, err
+		}
+
+		if err := b.checkLookup(topLevelDir, lookup, methodName); err != nil {
+			return nil, err
+		}
+
+		if e := b.debugTree(topLevelDir, methodName); e != nil {
 			return nil, e
 		}
 
-		for _, file := range topLevelDir.Files() {
-			b.logger.Debug("Gray{Debug➤ Found asset file} White{%s} with permission White{%s}",
-				file.Name().String(), file.PermissionSet().String())
+		assets = append(assets, methodAssets{
+			methodName: methodName,
+			annotation: methodAnnotation,
+			directory:  topLevelDir,
+			isDir:      isDir,
+			archive:    archive,
+			lookup:     lookup,
+		})
+	}
+
+	sharedDictionary, e := b.shareDictionary(assets)
+	if e != nil {
+		return nil, e
+	}
+
+	decryptMethodGenerated := false
+	for _, asset := range assets {
+		methodName, methodAnnotation, isDir := asset.methodName, asset.annotation, asset.isDir
+
+		var compressorID string
+		var buffer *bytes.Buffer
+		if asset.archive != nil {
+			compressorID, buffer = methodAnnotation.Compressor, bytes.NewBuffer(asset.archive)
+			b.logger.Debug("Gray{Debug➤ Embedding archive} LimeGreen{%s} Gray{for method} LimeGreen{%s} "+
+				"Gray{as-is with} LimeGreen{%d} Gray{bytes}", methodAnnotation.Asset, b.target.Name.Name+"#"+methodName,
+				buffer.Len())
+		} else {
+			if compressorID, buffer, e = b.compress(asset.directory, methodAnnotation, methodName, sharedDictionary); e != nil {
+				return nil, e
+			}
+
+			b.logger.Debug("Gray{Debug➤ Compressed asset} LimeGreen{%s} Gray{for method} LimeGreen{%s} "+
+				"Gray{to} LimeGreen{%d} Gray{bytes using} LimeGreen{%s}",
+				methodAnnotation.Asset, b.target.Name.Name+"#"+methodName, buffer.Len(), compressorID)
+		}
+
+		content := buffer.Bytes()
+		var signature []byte
+		if signingKey != nil { // Sign the compressed assets, so they are verified after they were decrypted
+			signature = compressor.Sign(signingKey, content)
+			b.logger.Debug("Gray{Debug➤ Signed assets for method} LimeGreen{%s}", b.target.Name.Name+"#"+methodName)
 		}
-		files.WalkDirectoryTree(topLevelDir, func(d files.Directory) {
-			b.logger.Debug("Gray{Debug➤ Found asset directory} White{%s} with permission White{%s}",
-				d.Name().String(), d.PermissionSet().String())
-			for _, file := range d.Files() {
-				b.logger.Debug("Gray{Debug➤ Found asset file} White{%s} with permission White{%s}",
-					file.Name().String(), file.PermissionSet().String())
+
+		if methodAnnotation.Encrypted {
+			if content, e = b.encrypt(content, methodName); e != nil {
+				return nil, e
 			}
-		})
 
-		compressorType := compressor.DefaultRegistry.Find(methodAnnotation.Compressor)
-		if compressorType == nil {
-			return nil, errors.New("could not find compressor for " + methodAnnotation.Compressor)
+			if !decryptMethodGenerated && signingKey == nil {
+				b.generateDecryptMethod(goGenerator, receiverType)
+				decryptMethodGenerated = true
+			}
 		}
-		buffer := &bytes.Buffer{}
-		if err := compressorType.Compress(topLevelDir, buffer); err != nil {
-			return nil, err
+
+		treeChecksum, e := b.treeChecksum(compressorID, buffer.Bytes(), methodName, sharedDictionary)
+		if e != nil {
+			return nil, e
 		}
 
-		b.logger.Debug("Gray{Debug➤ Compressed asset} LimeGreen{%s} Gray{for method} LimeGreen{%s} "+
-			"Gray{to} LimeGreen{%d} Gray{bytes}",
-			methodAnnotation.Asset, b.target.Name.Name+"#"+methodName, buffer.Len())
+		var assetProviderCall string
+		switch {
+		case signingKey != nil:
+			assetProviderCall = fmt.Sprintf("%s.%s(\"%s\", \"%s\", \"%s\", \"%s\", %t, \"%s\", \"%s\")",
+				receiverVariableName,
+				InternalVerifyMethod,
+				methodName,
+				compressorID,
+				hex.EncodeToString(signature),
+				hex.EncodeToString(content),
+				methodAnnotation.Encrypted,
+				compressor.Checksum(content),
+				treeChecksum)
 
-		goGenerator.Method(methodName, func(method generator.MethodGenerator) {
-			assetProviderCall := fmt.Sprintf("%s.%s(\"%s\", \"%s\")", receiverVariableName,
+		case methodAnnotation.Encrypted:
+			assetProviderCall = fmt.Sprintf("%s.%s(\"%s\", \"%s\", \"%s\", \"%s\", \"%s\")", receiverVariableName,
+				InternalDecryptMethod,
+				methodName,
+				compressorID,
+				hex.EncodeToString(content),
+				compressor.Checksum(content),
+				treeChecksum)
+
+		default:
+			assetProviderCall = fmt.Sprintf("%s.%s(\"%s\", \"%s\", \"%s\", \"%s\", \"%s\")", receiverVariableName,
 				InternalDecompressMethod,
-				methodAnnotation.Compressor,
-				hex.EncodeToString(buffer.Bytes()))
+				methodName,
+				compressorID,
+				hex.EncodeToString(content),
+				compressor.Checksum(content),
+				treeChecksum)
+		}
+
+		goGenerator.Method(methodName, func(method generator.MethodGenerator) {
 
 			method.Receiver(receiverType).ReturnTypes("files.Directory", "error")
 
+			if !isDir && asset.lookup == files.ExactLookup {
+				method.Body(fmt.Sprintf("return %s", assetProviderCall))
+				return
+			}
+
+			directory := "dir"
 			if isDir {
 				goGenerator.Import("github.com/homeport/pina-golada/pkg/files/paths")
-				method.Body(fmt.Sprintf("dir, decompressError := %s", assetProviderCall))
-				method.Body(fmt.Sprintf(`if decompressError != nil {return dir, decompressError}`))
-				method.Body(fmt.Sprintf(`return dir.Directory(paths.Of("%s")) , nil`,
-					filepath.Base(methodAnnotation.Asset)))
+				directory = fmt.Sprintf(`dir.Directory(paths.Of("%s"))`, filepath.Base(methodAnnotation.Asset))
+			}
+
+			method.Body(fmt.Sprintf("dir, decompressError := %s", assetProviderCall))
+			method.Body(fmt.Sprintf(`if decompressError != nil {return dir, decompressError}`))
+			if asset.lookup == files.ExactLookup {
+				method.Body(fmt.Sprintf(`return %s , nil`, directory))
 			} else {
-				method.Body(fmt.Sprintf("return %s", assetProviderCall))
+				method.Body(fmt.Sprintf(`return files.WithLookupMode(%s, %s)`, directory, lookupExpression(asset.lookup)))
 			}
 		})
 	}
 
 	goGenerator.Method("init", func(method generator.MethodGenerator) {
+		if sharedDictionary != nil {
+			method.Body(
+				fmt.Sprintf(`sharedDictionary, _ := hex.DecodeString("%s")`, hex.EncodeToString(sharedDictionary.dictionary)),
+				fmt.Sprintf(`compressor.DefaultRegistry.PutFactory("%s", compressor.SharedDictionary(sharedDictionary))`,
+					sharedDictionary.id))
+		}
 		method.Body(b.interfaceAnnotation.Injector + " = &" + structName + "{}")
 	})
 	b.logger.Debug("Gray{Debug➤ Generated initialize method for} LimeGreen{%s}", b.target.Name.Name)
@@ -221,3 +397,127 @@ func (b Builder) BuildFile() (by []byte, err error) {
 	goGenerator.Flush(outputBuffer)
 	return format.Source(outputBuffer.Bytes())
 }
+
+// debugTree logs the tree of the assets of the method on the debug level. The tree is only rendered if debug
+// messages are logged, as computing the sizes reads every file.
+func (b Builder) debugTree(directory files.Directory, methodName string) error {
+	if !b.logger.Enabled(logger.Debug) {
+		return nil
+	}
+
+	tree := &strings.Builder{}
+	if err := files.WriteTree(tree, directory, files.TreeOptions{
+		ShowPermission: true,
+		ShowSize:       true,
+		Sorted:         true,
+	}); err != nil {
+		return err
+	}
+
+	b.logger.Debug("Gray{Debug➤ Found assets for method} LimeGreen{%s}", b.target.Name.Name+"#"+methodName)
+	for _, line := range strings.Split(tree.String(), "\n") {
+		if len(line) > 0 {
+			b.logger.Debug("Gray{Debug➤}   White{%s}", line)
+		}
+	}
+	return nil
+}
+
+// lookupExpression returns the Go expression of the lookup mode in the generated code
+func lookupExpression(mode files.LookupMode) string {
+	var constants []string
+	if mode&files.CaseInsensitiveLookup != 0 {
+		constants = append(constants, "files.CaseInsensitiveLookup")
+	}
+	if mode&files.NormalizedLookup != 0 {
+		constants = append(constants, "files.NormalizedLookup")
+	}
+
+	if len(constants) == 0 {
+		return "files.ExactLookup"
+	}
+	return strings.Join(constants, " | ")
+}
+
+// loadAsset loads the file or directory at the path into a new root directory. Directories are loaded into a sub
+// directory with their name, which is why isDir is true for them. Directories without a name, e.g. the working
+// directory, are loaded into the root directory itself.
+func loadAsset(path string) (topLevelDir files.Directory, isDir bool, e error) {
+	topLevelDir = files.NewRootDirectory()
+	directory := topLevelDir
+
+	if i, e := os.Stat(path); e == nil && i.IsDir() {
+		if name := paths.Of(i.Name()); name.Valid() {
+			directory = directory.NewDirectory(name)
+			isDir = true
+		}
+	}
+
+	if e := files.LoadFromDisk(directory, path); e != nil {
+		return nil, false, e
+	}
+	return topLevelDir, isDir, nil
+}
+
+// checkLookup fails if names of the assets collide under the lookup mode of the method, as the generated method
+// would fail on every call otherwise
+func (b Builder) checkLookup(directory files.Directory, mode files.LookupMode, methodName string) error {
+	if mode == files.ExactLookup {
+		return nil
+	}
+
+	if _, e := files.WithLookupMode(directory, mode); e != nil {
+		return fmt.Errorf("assets of %s collide under the %s lookup: %w", b.target.Name.Name+"#"+methodName,
+			mode.String(), e)
+	}
+	return nil
+}
+
+// validatePortable checks the names of the assets against the platforms of the interface annotation. Depending
+// on the validation of the annotation, names that are not portable are logged or fail the generation.
+func (b Builder) validatePortable(directory files.Directory, methodName string) error {
+	validation := strings.ToLower(b.interfaceAnnotation.Validation)
+	switch validation {
+	case ValidationOff:
+		return nil
+
+	case "":
+		validation = ValidationWarn
+
+	case ValidationWarn, ValidationFail:
+
+	default:
+		return fmt.Errorf("unknown validation %s on %s, expected %s, %s or %s", b.interfaceAnnotation.Validation,
+			b.target.Name.Name, ValidationWarn, ValidationFail, ValidationOff)
+	}
+
+	platforms := paths.AllPlatforms
+	if len(b.interfaceAnnotation.Platforms) > 0 {
+		parsed, e := paths.ParsePlatforms(b.interfaceAnnotation.Platforms)
+		if e != nil {
+			return fmt.Errorf("invalid platforms on %s: %w", b.target.Name.Name, e)
+		}
+		platforms = parsed
+	}
+
+	problems := files.ValidatePortable(directory, platforms)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	methodIdentifier := b.target.Name.Name + "#" + methodName
+	if validation == ValidationWarn {
+		for _, problem := range problems {
+			b.logger.Info("Orange{Warning➤} Gray{Asset of} LimeGreen{%s} Gray{is not portable:} White{%s}",
+				methodIdentifier, problem.Error())
+		}
+		return nil
+	}
+
+	messages := make([]string, len(problems))
+	for index, problem := range problems {
+		messages[index] = problem.Error()
+	}
+	return fmt.Errorf("assets of %s are not portable to %s: %s", methodIdentifier, platforms.String(),
+		strings.Join(messages, "; "))
+}
@@ -21,15 +21,24 @@
 package builder
 
 import (
-	"github.com/homeport/pina-golada/internal/golada/logger"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/homeport/pina-golada/internal/golada/logger"
 	"github.com/homeport/pina-golada/pkg/annotation"
+	"github.com/homeport/pina-golada/pkg/compressor"
 	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
 	"github.com/homeport/pina-golada/pkg/inspector"
 )
 
@@ -51,6 +60,15 @@ type AssetProvider interface {
 
 	// @pgl(asset=../../../assets/tests/issue-27/directory-2&compressor=tar)
 	GetInfoFileNo2() (d files.Directory, err error)
+
+	// @pgl(asset=../../../assets/tests/issue-27/directory-1&compressor=zip&options=store:.txt)
+	GetStoredInfoFile() (d files.Directory, err error)
+
+	// @pgl(asset=../../../assets/tests/issue-27/directory-2&compressor=tar&level=1)
+	GetFastInfoFile() (d files.Directory, err error)
+
+	// @pgl(asset=../../../assets/tests/tar-archives/web-ui.tar.gz&source=archive)
+	GetWebUI() (d files.Directory, err error)
 }
 
 var _ = Describe("should generate files correctly", func() {
@@ -130,4 +148,513 @@ var _ = Describe("should generate files correctly", func() {
 		Expect(b).To(Not(BeNil()))
 		Expect(strings.Count(string(b), "func init() ()")).To(BeEquivalentTo(0))
 	})
+
+	_ = It("should generate identical files from the same assets", func() {
+		stream, e := inspector.NewFileStream("./")
+		Expect(e).To(BeNil())
+
+		interfaces := inspector.NewAstStream(stream.Filter(func(file inspector.File) bool {
+			return strings.Contains(file.FileInfo.Name(), "builder_test.go")
+		})).Find()
+		Expect(len(interfaces)).To(BeEquivalentTo(1))
+
+		generate := func() []byte {
+			b, e := NewBuilder(interfaces[0], &PinaGoladaInterface{Injector: "AssetInjector"},
+				annotation.NewPropertyParser(), l).BuildFile()
+			Expect(e).To(BeNil())
+			return b
+		}
+
+		Expect(generate()).To(Equal(generate()))
+	})
+
+	_ = It("should load directories into a sub directory unless they have no name", func() {
+		tempDir, e := ioutil.TempDir("", "pgl-asset")
+		Expect(e).To(BeNil())
+		defer os.RemoveAll(tempDir)
+		Expect(ioutil.WriteFile(filepath.Join(tempDir, "file.txt"), []byte("content"), 0644)).To(BeNil())
+
+		directory, isDir, e := loadAsset(tempDir)
+		Expect(e).To(BeNil())
+		Expect(isDir).To(BeTrue())
+		Expect(directory.File(paths.Of(filepath.Base(tempDir) + "/file.txt"))).ToNot(BeNil())
+
+		directory, isDir, e = loadAsset(tempDir + string(filepath.Separator) + ".")
+		Expect(e).To(BeNil())
+		Expect(isDir).To(BeFalse())
+		Expect(directory.File(paths.Of("file.txt"))).ToNot(BeNil())
+	})
+
+	_ = It("should only render the tree of the assets on the debug level", func() {
+		loads := 0
+		directory := files.NewRootDirectory()
+		_, e := files.NewLazyFile(directory, paths.Of("assets/lazy.txt"), func() ([]byte, error) {
+			loads++
+			return []byte("lazy"), nil
+		})
+		Expect(e).To(BeNil())
+
+		output := &bytes.Buffer{}
+		builder := Builder{logger: logger.NewDefaultLogger(output, logger.Info)}
+		Expect(builder.debugTree(directory, "GetLazy")).To(BeNil())
+		Expect(loads).To(Equal(0))
+		Expect(output.Len()).To(Equal(0))
+	})
+
+	_ = It("should fail if asset names collide under the lookup mode", func() {
+		stream, e := inspector.NewFileStream("./")
+		Expect(e).To(BeNil())
+
+		interfaces := inspector.NewAstStream(stream.Filter(func(file inspector.File) bool {
+			return strings.Contains(file.FileInfo.Name(), "builder_test.go")
+		})).Find()
+		Expect(len(interfaces)).To(BeEquivalentTo(1))
+
+		builder := NewBuilder(interfaces[0], &PinaGoladaInterface{Injector: "AssetInjector"},
+			annotation.NewPropertyParser(), l)
+
+		directory := files.NewRootDirectory()
+		Expect(directory.NewFile(paths.Of("assets/A.txt")).Write(bytes.NewBufferString("A"))).To(BeNil())
+		Expect(directory.NewFile(paths.Of("assets/a.txt")).Write(bytes.NewBufferString("a"))).To(BeNil())
+
+		Expect(builder.checkLookup(directory, files.ExactLookup, "GetMainGoFile")).To(BeNil())
+		Expect(builder.checkLookup(directory, files.NormalizedLookup, "GetMainGoFile")).To(BeNil())
+
+		e = builder.checkLookup(directory, files.CaseInsensitiveLookup, "GetMainGoFile")
+		var collision *files.CollisionError
+		Expect(errors.As(e, &collision)).To(BeTrue())
+		Expect(e.Error()).To(ContainSubstring("AssetProvider#GetMainGoFile"))
+	})
+
+	_ = Context("when validating the portability of asset names", func() {
+		var (
+			directory files.Directory
+			output    *bytes.Buffer
+		)
+
+		newBuilder := func(interfaceAnnotation *PinaGoladaInterface) *Builder {
+			stream, e := inspector.NewFileStream("./")
+			Expect(e).To(BeNil())
+
+			interfaces := inspector.NewAstStream(stream.Filter(func(file inspector.File) bool {
+				return strings.Contains(file.FileInfo.Name(), "builder_test.go")
+			})).Find()
+			Expect(len(interfaces)).To(BeEquivalentTo(1))
+
+			interfaceAnnotation.Injector = "AssetInjector"
+			return NewBuilder(interfaces[0], interfaceAnnotation, annotation.NewPropertyParser(),
+				logger.NewDefaultLogger(output, logger.Info))
+		}
+
+		_ = BeforeEach(func() {
+			output = &bytes.Buffer{}
+			directory = files.NewRootDirectory()
+			Expect(directory.NewFile(paths.Of("assets/aux.txt")).Write(bytes.NewBufferString("aux"))).To(BeNil())
+		})
+
+		_ = It("should warn by default", func() {
+			Expect(newBuilder(&PinaGoladaInterface{}).validatePortable(directory, "GetMainGoFile")).To(BeNil())
+			Expect(output.String()).To(ContainSubstring("aux.txt"))
+		})
+
+		_ = It("should fail if requested", func() {
+			e := newBuilder(&PinaGoladaInterface{Validation: ValidationFail}).validatePortable(directory, "GetMainGoFile")
+			Expect(e).ToNot(BeNil())
+			Expect(e.Error()).To(ContainSubstring("AssetProvider#GetMainGoFile"))
+		})
+
+		_ = It("should only check the requested platforms", func() {
+			builder := newBuilder(&PinaGoladaInterface{Platforms: "linux,darwin", Validation: ValidationFail})
+			Expect(builder.validatePortable(directory, "GetMainGoFile")).To(BeNil())
+		})
+
+		_ = It("should reject unknown settings", func() {
+			Expect(newBuilder(&PinaGoladaInterface{Validation: "maybe"}).validatePortable(directory, "GetMainGoFile")).ToNot(BeNil())
+			Expect(newBuilder(&PinaGoladaInterface{Platforms: "plan9"}).validatePortable(directory, "GetMainGoFile")).ToNot(BeNil())
+		})
+	})
+
+	_ = Context("when encrypting assets", func() {
+		var builder *Builder
+
+		_ = BeforeEach(func() {
+			stream, e := inspector.NewFileStream("./")
+			Expect(e).To(BeNil())
+
+			interfaces := inspector.NewAstStream(stream.Filter(func(file inspector.File) bool {
+				return strings.Contains(file.FileInfo.Name(), "builder_test.go")
+			})).Find()
+			Expect(len(interfaces)).To(BeEquivalentTo(1))
+
+			builder = NewBuilder(interfaces[0], &PinaGoladaInterface{Injector: "AssetInjector"},
+				annotation.NewPropertyParser(), l)
+		})
+
+		_ = It("should seal the assets with the key of the environment", func() {
+			Expect(os.Setenv("PINA_GOLADA_BUILDER_TEST_KEY", strings.Repeat("ab", compressor.KeySize))).To(BeNil())
+			defer os.Unsetenv("PINA_GOLADA_BUILDER_TEST_KEY")
+			builder.interfaceAnnotation.KeyEnvironment = "PINA_GOLADA_BUILDER_TEST_KEY"
+
+			sealed, e := builder.encrypt([]byte("assets"), "GetMainGoFile")
+			Expect(e).To(BeNil())
+
+			key, e := compressor.DecodeKey(strings.Repeat("ab", compressor.KeySize))
+			Expect(e).To(BeNil())
+			plain, e := compressor.Open(key, sealed)
+			Expect(e).To(BeNil())
+			Expect(string(plain)).To(BeEquivalentTo("assets"))
+		})
+
+		_ = It("should fail without a key", func() {
+			builder.interfaceAnnotation.KeyEnvironment = "PINA_GOLADA_BUILDER_TEST_MISSING_KEY"
+
+			_, e := builder.encrypt([]byte("assets"), "GetMainGoFile")
+			Expect(errors.Is(e, compressor.ErrNoKey)).To(BeTrue())
+			Expect(e.Error()).To(ContainSubstring("GetMainGoFile"))
+
+			builder.interfaceAnnotation.KeyFile = "missing-key-file"
+			_, e = builder.encrypt([]byte("assets"), "GetMainGoFile")
+			Expect(e).ToNot(BeNil())
+		})
+	})
+
+	_ = Context("when signing assets", func() {
+		newBuilder := func(interfaceAnnotation *PinaGoladaInterface) *Builder {
+			stream, e := inspector.NewFileStream("./")
+			Expect(e).To(BeNil())
+
+			interfaces := inspector.NewAstStream(stream.Filter(func(file inspector.File) bool {
+				return strings.Contains(file.FileInfo.Name(), "builder_test.go")
+			})).Find()
+			Expect(len(interfaces)).To(BeEquivalentTo(1))
+
+			interfaceAnnotation.Injector = "AssetInjector"
+			return NewBuilder(interfaces[0], interfaceAnnotation, annotation.NewPropertyParser(), l)
+		}
+
+		_ = It("should embed signatures and the public key", func() {
+			Expect(os.Setenv("PINA_GOLADA_BUILDER_TEST_SIGNING_KEY", strings.Repeat("01", 32))).To(BeNil())
+			defer os.Unsetenv("PINA_GOLADA_BUILDER_TEST_SIGNING_KEY")
+
+			b, e := newBuilder(&PinaGoladaInterface{SigningKeyEnvironment: "PINA_GOLADA_BUILDER_TEST_SIGNING_KEY"}).BuildFile()
+			Expect(e).To(BeNil())
+			Expect(strings.Count(string(b), "p."+InternalVerifyMethod+"(")).To(BeEquivalentTo(6))
+			Expect(string(b)).ToNot(ContainSubstring("p." + InternalDecompressMethod + "("))
+		})
+
+		_ = It("should not sign without a signing key", func() {
+			b, e := newBuilder(&PinaGoladaInterface{}).BuildFile()
+			Expect(e).To(BeNil())
+			Expect(string(b)).ToNot(ContainSubstring(InternalVerifyMethod))
+		})
+
+		_ = It("should reject invalid signing keys", func() {
+			_, e := newBuilder(&PinaGoladaInterface{SigningKeyEnvironment: "PINA_GOLADA_BUILDER_TEST_MISSING_KEY"}).BuildFile()
+			Expect(errors.Is(e, compressor.ErrInvalidSigningKey)).To(BeTrue())
+
+			_, e = newBuilder(&PinaGoladaInterface{SigningKeyFile: "missing-signing-key"}).BuildFile()
+			Expect(e).ToNot(BeNil())
+		})
+
+		_ = It("should read the signing key file and environment from the annotation", func() {
+			interfaceAnnotation := &PinaGoladaInterface{}
+			Expect(annotation.NewPropertyParser().Parse("@pgl(injector=Provider&signingkeyfile=/secrets/signing.key"+
+				"&signingkeyenv=SIGNING_KEY)", interfaceAnnotation)).To(BeNil())
+			Expect(interfaceAnnotation.SigningKeyFile).To(Equal("/secrets/signing.key"))
+			Expect(interfaceAnnotation.SigningKeyEnvironment).To(Equal("SIGNING_KEY"))
+		})
+	})
+
+	_ = Context("when sharing a dictionary", func() {
+		newAssets := func(compressors ...string) []methodAssets {
+			var assets []methodAssets
+			for index, id := range compressors {
+				directory := files.NewRootDirectory()
+				content := fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config-%d\n", index)
+				Expect(directory.NewFile(paths.Of("config.yml")).Write(bytes.NewBufferString(content))).To(BeNil())
+
+				assets = append(assets, methodAssets{
+					methodName: fmt.Sprintf("GetConfig%d", index),
+					annotation: &PinaGoladaMethod{Compressor: id},
+					directory:  directory,
+				})
+			}
+			return assets
+		}
+
+		newBuilder := func(interfaceAnnotation *PinaGoladaInterface) *Builder {
+			stream, e := inspector.NewFileStream("./")
+			Expect(e).To(BeNil())
+
+			interfaces := inspector.NewAstStream(stream.Filter(func(file inspector.File) bool {
+				return strings.Contains(file.FileInfo.Name(), "builder_test.go")
+			})).Find()
+			Expect(len(interfaces)).To(BeEquivalentTo(1))
+
+			return NewBuilder(interfaces[0], interfaceAnnotation, annotation.NewPropertyParser(), l)
+		}
+
+		_ = It("should share one dictionary across the methods using the dictionary compressor", func() {
+			assets := newAssets(DictionaryCompressor, "tar", DictionaryCompressor)
+
+			shared, e := newBuilder(&PinaGoladaInterface{SharedDictionary: true}).shareDictionary(assets)
+			Expect(e).To(BeNil())
+			Expect(shared).ToNot(BeNil())
+			Expect(shared.dictionary).To(ContainSubstring("kind: ConfigMap"))
+
+			Expect(assets[0].annotation.Compressor).To(Equal(shared.id))
+			Expect(assets[1].annotation.Compressor).To(Equal("tar"))
+			Expect(assets[2].annotation.Compressor).To(Equal(shared.id))
+			Expect(compressor.DefaultRegistry.Find(shared.id)).To(BeNil())
+
+			created, e := shared.create(shared.id, compressor.Options{})
+			Expect(e).To(BeNil())
+			Expect(created).ToNot(BeNil())
+		})
+
+		_ = It("should not share a dictionary unless requested", func() {
+			assets := newAssets(DictionaryCompressor)
+
+			shared, e := newBuilder(&PinaGoladaInterface{}).shareDictionary(assets)
+			Expect(e).To(BeNil())
+			Expect(shared).To(BeNil())
+			Expect(assets[0].annotation.Compressor).To(Equal(DictionaryCompressor))
+
+			shared, e = newBuilder(&PinaGoladaInterface{SharedDictionary: true}).shareDictionary(newAssets("tar"))
+			Expect(e).To(BeNil())
+			Expect(shared).To(BeNil())
+		})
+	})
+
+	_ = Context("when selecting the compressor automatically", func() {
+		var (
+			directory files.Directory
+			builder   *Builder
+		)
+
+		_ = BeforeEach(func() {
+			stream, e := inspector.NewFileStream("./")
+			Expect(e).To(BeNil())
+
+			interfaces := inspector.NewAstStream(stream.Filter(func(file inspector.File) bool {
+				return strings.Contains(file.FileInfo.Name(), "builder_test.go")
+			})).Find()
+			Expect(len(interfaces)).To(BeEquivalentTo(1))
+
+			builder = NewBuilder(interfaces[0], &PinaGoladaInterface{Injector: "AssetInjector"},
+				annotation.NewPropertyParser(), l)
+
+			directory = files.NewRootDirectory()
+			content := strings.Repeat("pina-golada ", 1024)
+			Expect(directory.NewFile(paths.Of("assets/a.txt")).Write(bytes.NewBufferString(content))).To(BeNil())
+			Expect(directory.NewFile(paths.Of("assets/b.txt")).Write(bytes.NewBufferString(content))).To(BeNil())
+		})
+
+		_ = It("should pick the smallest output", func() {
+			id, output, e := builder.compress(directory, &PinaGoladaMethod{Compressor: AutoCompressor}, "GetMainGoFile", nil)
+			Expect(e).To(BeNil())
+			Expect(id).ToNot(Equal(AutoCompressor))
+
+			for _, candidate := range compressor.DefaultRegistry.IDs() {
+				_, other, e := builder.compress(directory, &PinaGoladaMethod{Compressor: candidate}, "GetMainGoFile", nil)
+				Expect(e).To(BeNil())
+				Expect(output.Len()).To(BeNumerically("<=", other.Len()))
+			}
+
+			restored, e := compressor.DefaultRegistry.Find(id).Decompress(context.Background(), bytes.NewReader(output.Bytes()))
+			Expect(e).To(BeNil())
+			Expect(restored.File(paths.Of("assets/a.txt"))).ToNot(BeNil())
+		})
+
+		_ = It("should only try the configured candidates", func() {
+			id, _, e := builder.compress(directory, &PinaGoladaMethod{
+				Compressor: AutoCompressor,
+				Candidates: "store|none",
+			}, "GetMainGoFile", nil)
+			Expect(e).To(BeNil())
+			Expect(id).To(Equal("store"))
+		})
+
+		_ = It("should stay within the tolerance of the smallest output when balancing", func() {
+			_, smallest, e := builder.compress(directory, &PinaGoladaMethod{Compressor: AutoCompressor}, "GetMainGoFile", nil)
+			Expect(e).To(BeNil())
+
+			_, balanced, e := builder.compress(directory, &PinaGoladaMethod{
+				Compressor: AutoCompressor,
+				Selection:  SelectionBalanced,
+			}, "GetMainGoFile", nil)
+			Expect(e).To(BeNil())
+			Expect(float64(balanced.Len())).To(BeNumerically("<=", float64(smallest.Len())*balancedTolerance))
+		})
+
+		_ = It("should prefer the lowest decode cost and then the lowest id when balancing", func() {
+			result := func(id string, size int) compressionResult {
+				return compressionResult{id: id, output: bytes.NewBuffer(make([]byte, size))}
+			}
+
+			results := []compressionResult{result("tar", 100), result("zip", 104), result("tar+zlib", 105),
+				result("indexed", 108), result("store", 500)}
+			Expect(selectResult(results, SelectionSmallest).id).To(Equal("tar"))
+			Expect(selectResult(results, SelectionBalanced).id).To(Equal("indexed"))
+
+			results = []compressionResult{result("zip", 100), result("tar", 101), result("dictionary", 102)}
+			Expect(selectResult(results, SelectionBalanced).id).To(Equal("dictionary"))
+			Expect(decodeCost("TAR+NONE")).To(Equal(0))
+			Expect(decodeCost("unknown")).To(Equal(unknownDecodeCost))
+		})
+
+		_ = It("should skip candidates that do not accept the options", func() {
+			id, _, e := builder.compress(directory, &PinaGoladaMethod{
+				Compressor: AutoCompressor,
+				Candidates: "store|zip",
+				Options:    "store:.txt",
+			}, "GetMainGoFile", nil)
+			Expect(e).To(BeNil())
+			Expect(id).To(Equal("zip"))
+
+			_, _, e = builder.compress(directory, &PinaGoladaMethod{
+				Compressor: AutoCompressor,
+				Candidates: "store",
+				Level:      1,
+			}, "GetMainGoFile", nil)
+			Expect(e).ToNot(BeNil())
+		})
+
+		_ = It("should reject unknown selections", func() {
+			_, _, e := builder.compress(directory, &PinaGoladaMethod{Compressor: AutoCompressor, Selection: "fastest"},
+				"GetMainGoFile", nil)
+			Expect(e).ToNot(BeNil())
+		})
+	})
+
+	_ = Context("when embedding archives", func() {
+		var (
+			builder *Builder
+			tempDir string
+		)
+
+		_ = BeforeEach(func() {
+			stream, e := inspector.NewFileStream("./")
+			Expect(e).To(BeNil())
+
+			interfaces := inspector.NewAstStream(stream.Filter(func(file inspector.File) bool {
+				return strings.Contains(file.FileInfo.Name(), "builder_test.go")
+			})).Find()
+			Expect(len(interfaces)).To(BeEquivalentTo(1))
+
+			builder = NewBuilder(interfaces[0], &PinaGoladaInterface{Injector: "AssetInjector"},
+				annotation.NewPropertyParser(), l)
+
+			tempDir, e = ioutil.TempDir("", "pgl-archive")
+			Expect(e).To(BeNil())
+		})
+
+		_ = AfterEach(func() {
+			Expect(os.RemoveAll(tempDir)).To(BeNil())
+		})
+
+		// writeArchive writes the content to a temporary file with the name and returns its path
+		writeArchive := func(name string, content []byte) string {
+			path := filepath.Join(tempDir, name)
+			Expect(ioutil.WriteFile(path, content, 0644)).To(BeNil())
+			return path
+		}
+
+		_ = It("should embed archives of a matching format as-is", func() {
+			methodAnnotation := &PinaGoladaMethod{Asset: "../../../assets/tests/tar-archives/web-ui.tar.gz", Source: SourceArchive}
+
+			directory, archive, e := builder.loadArchive(methodAnnotation, "GetWebUI")
+			Expect(e).To(BeNil())
+			Expect(methodAnnotation.Compressor).To(Equal("tar"))
+			Expect(directory.File(paths.Of("dist/index.html"))).ToNot(BeNil())
+
+			content, e := ioutil.ReadFile(methodAnnotation.Asset)
+			Expect(e).To(BeNil())
+			Expect(archive).To(Equal(content))
+		})
+
+		_ = It("should detect plain tar archives", func() {
+			methodAnnotation := &PinaGoladaMethod{Asset: "../../../assets/tests/tar-archives/gnu-hard-links.tar", Source: SourceArchive}
+
+			directory, archive, e := builder.loadArchive(methodAnnotation, "GetWebUI")
+			Expect(e).To(BeNil())
+			Expect(archive).ToNot(BeNil())
+			Expect(methodAnnotation.Compressor).To(Equal("tar+identity"))
+			Expect(directory.File(paths.Of("dir/link.txt"))).ToNot(BeNil())
+		})
+
+		_ = It("should compress re-rooted archives again", func() {
+			methodAnnotation := &PinaGoladaMethod{
+				Asset:      "../../../assets/tests/tar-archives/web-ui.tar.gz",
+				Source:     SourceArchive,
+				Compressor: "tar",
+				Root:       "dist",
+			}
+
+			directory, archive, e := builder.loadArchive(methodAnnotation, "GetWebUI")
+			Expect(e).To(BeNil())
+			Expect(archive).To(BeNil())
+			Expect(directory.File(paths.Of("index.html"))).ToNot(BeNil())
+			Expect(directory.File(paths.Of("static/style.css"))).ToNot(BeNil())
+			Expect(directory.File(paths.Of("README.md"))).To(BeNil())
+
+			methodAnnotation.Root = "missing"
+			_, _, e = builder.loadArchive(methodAnnotation, "GetWebUI")
+			Expect(e).ToNot(BeNil())
+
+			methodAnnotation.Root = "."
+			_, _, e = builder.loadArchive(methodAnnotation, "GetWebUI")
+			Expect(e).ToNot(BeNil())
+		})
+
+		_ = It("should compress archives again with the compressor of the archive by default", func() {
+			for _, methodAnnotation := range []*PinaGoladaMethod{
+				{Root: "dist"},
+				{Level: 1},
+			} {
+				methodAnnotation.Asset, methodAnnotation.Source = "../../../assets/tests/tar-archives/web-ui.tar.gz", SourceArchive
+
+				_, archive, e := builder.loadArchive(methodAnnotation, "GetWebUI")
+				Expect(e).To(BeNil())
+				Expect(archive).To(BeNil())
+				Expect(methodAnnotation.Compressor).To(Equal("tar"))
+			}
+		})
+
+		_ = It("should compress archives again for a different compressor or options", func() {
+			for _, methodAnnotation := range []*PinaGoladaMethod{
+				{Compressor: "zip"},
+				{Compressor: AutoCompressor},
+				{Compressor: "tar", Level: 1},
+			} {
+				methodAnnotation.Asset, methodAnnotation.Source = "../../../assets/tests/tar-archives/web-ui.tar.gz", SourceArchive
+				compressorID := methodAnnotation.Compressor
+
+				_, archive, e := builder.loadArchive(methodAnnotation, "GetWebUI")
+				Expect(e).To(BeNil())
+				Expect(archive).To(BeNil())
+				Expect(methodAnnotation.Compressor).To(Equal(compressorID))
+			}
+		})
+
+		_ = It("should reject invalid archives", func() {
+			_, _, e := builder.loadArchive(&PinaGoladaMethod{
+				Asset:  writeArchive("dist.tar.gz", []byte("no gzip stream")),
+				Source: SourceArchive,
+			}, "GetWebUI")
+			Expect(e).ToNot(BeNil())
+
+			_, _, e = builder.loadArchive(&PinaGoladaMethod{
+				Asset:  writeArchive("dist.rar", []byte("Rar!")),
+				Source: SourceArchive,
+			}, "GetWebUI")
+			Expect(e).ToNot(BeNil())
+
+			_, _, e = builder.loadArchive(&PinaGoladaMethod{
+				Asset:  "../../../assets/tests/tar-archives",
+				Source: SourceArchive,
+			}, "GetWebUI")
+			Expect(e).ToNot(BeNil())
+		})
+	})
 })
@@ -0,0 +1,202 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package builder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/homeport/pina-golada/pkg/compressor"
+	"github.com/homeport/pina-golada/pkg/files"
+)
+
+const (
+	// AutoCompressor selects the compressor of an asset automatically
+	AutoCompressor = "auto"
+
+	// SelectionSmallest selects the candidate with the smallest output, which is the default
+	SelectionSmallest = "smallest"
+
+	// SelectionBalanced selects the candidate with the lowest decode cost among those whose output is at most
+	// balancedTolerance times the size of the smallest output
+	SelectionBalanced = "balanced"
+
+	// balancedTolerance is the factor by which the output of a balanced selection may exceed the smallest output
+	balancedTolerance = 1.1
+
+	// candidateSeparator separates the compressor ids of the candidates
+	candidateSeparator = "|"
+
+	// unknownDecodeCost is the decode cost of compressors that are not ranked, they are assumed to be the slowest
+	unknownDecodeCost = 4
+)
+
+var (
+	// decodeCosts ranks the compressors by the work it takes to decompress their output, from copying stored
+	// content over inflating only the files that are read to inflating everything. Pipelines add up the costs of
+	// their archiver and codec.
+	decodeCosts = map[string]int{"store": 0, "none": 0, "indexed": 1, "parallel": 2, "zip": 3, "tar": 3, "dictionary": 3}
+
+	// archiverCosts and codecCosts rank the parts of pipelines like decodeCosts
+	archiverCosts = map[string]int{"store": 0, "tar": 0, "zip": 3}
+	codecCosts    = map[string]int{"identity": 0, "none": 0, "pgzip": 2, "gzip": 3, "zlib": 3, "flate": 3}
+)
+
+// compressionResult is the output of one compressor
+type compressionResult struct {
+	id     string
+	output *bytes.Buffer
+}
+
+// compress compresses the directory using the compressor of the method annotation and returns the id of the used
+// compressor. The AutoCompressor tries the candidates of the annotation, or every compressor of the registry,
+// and picks one of them based on the selection of the annotation. The shared dictionary may be nil.
+func (b Builder) compress(directory files.Directory, annotation *PinaGoladaMethod, methodName string,
+	shared *sharedDictionary) (string, *bytes.Buffer, error) {
+	options, e := compressor.ParseOptions(annotation.Level, annotation.Options)
+	if e != nil {
+		return "", nil, fmt.Errorf("invalid compressor options for %s: %w", methodName, e)
+	}
+
+	if !strings.EqualFold(annotation.Compressor, AutoCompressor) {
+		result, e := compressWith(directory, annotation.Compressor, options, shared)
+		if e != nil {
+			return "", nil, fmt.Errorf("could not compress assets for %s: %w", methodName, e)
+		}
+		return result.id, result.output, nil
+	}
+
+	selection := strings.ToLower(annotation.Selection)
+	switch selection {
+	case "":
+		selection = SelectionSmallest
+
+	case SelectionSmallest, SelectionBalanced:
+
+	default:
+		return "", nil, fmt.Errorf("unknown selection %s for %s, expected %s or %s", annotation.Selection, methodName,
+			SelectionSmallest, SelectionBalanced)
+	}
+
+	candidates := compressor.DefaultRegistry.IDs()
+
+	if len(annotation.Candidates) > 0 {
+		candidates = strings.Split(annotation.Candidates, candidateSeparator)
+	}
+
+	methodIdentifier := b.target.Name.Name + "#" + methodName
+	var results []compressionResult
+	for _, candidate := range candidates {
+		candidate = strings.ToLower(strings.TrimSpace(candidate))
+		if candidate == AutoCompressor || len(candidate) == 0 {
+			continue
+		}
+
+		result, e := compressWith(directory, candidate, options, shared)
+		if e != nil {
+			b.logger.Debug("Gray{Debug➤ Skipped compressor} LimeGreen{%s} Gray{for} LimeGreen{%s}Gray{:} White{%s}",
+				candidate, methodIdentifier, e.Error())
+			continue
+		}
+
+		b.logger.Debug("Gray{Debug➤ Compressor} LimeGreen{%s} Gray{compressed} LimeGreen{%s} Gray{to} "+
+			"LimeGreen{%d} Gray{bytes with a decode cost of} LimeGreen{%d}",
+			candidate, methodIdentifier, result.output.Len(), decodeCost(candidate))
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		return "", nil, fmt.Errorf("none of the compressors %s could compress the assets for %s",
+			strings.Join(candidates, ", "), methodName)
+	}
+
+	selected := selectResult(results, selection)
+	b.logger.Debug("Gray{Debug➤ Selected compressor} LimeGreen{%s} Gray{for} LimeGreen{%s} Gray{as the %s candidate}",
+		selected.id, methodIdentifier, selection)
+
+	return selected.id, selected.output, nil
+}
+
+// selectResult returns the result of the selection. Ties of the smallest output are resolved in favour of the
+// earlier result, ties of the decode cost in favour of the lower id.
+func selectResult(results []compressionResult, selection string) compressionResult {
+	smallest := results[0]
+	for _, result := range results[1:] {
+		if result.output.Len() < smallest.output.Len() {
+			smallest = result
+		}
+	}
+
+	if selection != SelectionBalanced {
+		return smallest
+	}
+
+	cheapest := smallest
+	for _, result := range results {
+		if float64(result.output.Len()) > float64(smallest.output.Len())*balancedTolerance {
+			continue
+		}
+
+		cost, cheapestCost := decodeCost(result.id), decodeCost(cheapest.id)
+		if cost < cheapestCost || cost == cheapestCost && result.id < cheapest.id {
+			cheapest = result
+		}
+	}
+
+	return cheapest
+}
+
+// decodeCost returns the rank of the compressor of the id in decodeCosts, or the sum of the ranks of the
+// archiver and codec of a pipeline
+func decodeCost(id string) int {
+	id = strings.ToLower(id)
+	if cost, ok := decodeCosts[id]; ok {
+		return cost
+	}
+
+	parts := strings.SplitN(id, "+", 2)
+	if len(parts) == 2 {
+		archiverCost, knownArchiver := archiverCosts[parts[0]]
+		codecCost, knownCodec := codecCosts[parts[1]]
+		if knownArchiver && knownCodec {
+			return archiverCost + codecCost
+		}
+	}
+
+	return unknownDecodeCost
+}
+
+// compressWith compresses the directory with the compressor of the id, see sharedDictionary.create
+func compressWith(directory files.Directory, id string, options compressor.Options, shared *sharedDictionary) (compressionResult, error) {
+	instance, e := shared.create(id, options)
+	if e != nil {
+		return compressionResult{}, e
+	}
+
+	output := &bytes.Buffer{}
+	if err := instance.Compress(context.Background(), directory, output); err != nil {
+		return compressionResult{}, err
+	}
+
+	return compressionResult{id: id, output: output}, nil
+}
@@ -0,0 +1,99 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package builder
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/homeport/pina-golada/pkg/compressor"
+	"github.com/homeport/pina-golada/pkg/files"
+)
+
+// DictionaryCompressor is the id of the compressor that deflates files with a preset dictionary
+const DictionaryCompressor = "dictionary"
+
+// sharedDictionary is a preset dictionary that is shared across the methods of an interface and the id of the
+// compressor that uses it
+type sharedDictionary struct {
+	id         string
+	dictionary []byte
+}
+
+// shareDictionary builds a dictionary off of the assets of all methods that use the DictionaryCompressor if the
+// interface annotation requests a shared dictionary. Archives that are embedded as-is keep their compressor. The
+// compressor of these methods is replaced by an id derived from the dictionary, which only the shared dictionary
+// creates compressors for. It returns nil if no dictionary is shared.
+func (b Builder) shareDictionary(assets []methodAssets) (*sharedDictionary, error) {
+	if !b.interfaceAnnotation.SharedDictionary {
+		return nil, nil
+	}
+
+	var samples [][]byte
+	var methods []*PinaGoladaMethod
+	for _, asset := range assets {
+		if asset.archive != nil || !strings.EqualFold(asset.annotation.Compressor, DictionaryCompressor) {
+			continue
+		}
+
+		methods = append(methods, asset.annotation)
+
+		var readError error
+		files.WalkFileTree(asset.directory, func(file files.File) {
+			content := &bytes.Buffer{}
+			if readError == nil {
+				readError = file.CopyContent(content)
+				samples = append(samples, content.Bytes())
+			}
+		})
+
+		if readError != nil {
+			return nil, readError
+		}
+	}
+
+	if len(methods) == 0 {
+		return nil, nil
+	}
+
+	dictionary := compressor.BuildDictionary(samples, compressor.MaxDictionarySize)
+	shared := &sharedDictionary{
+		id:         DictionaryCompressor + "/" + compressor.Checksum(dictionary)[:16],
+		dictionary: dictionary,
+	}
+
+	for _, method := range methods {
+		method.Compressor = shared.id
+	}
+
+	b.logger.Debug("Gray{Debug➤ Built shared dictionary of} LimeGreen{%d} Gray{bytes for} LimeGreen{%d} "+
+		"Gray{methods of} LimeGreen{%s}", len(dictionary), len(methods), b.target.Name.Name)
+	return shared, nil
+}
+
+// create creates the compressor of the id. The id of the shared dictionary creates a compressor using the
+// dictionary, all other ids are created by the default registry. The shared dictionary may be nil.
+func (s *sharedDictionary) create(id string, options compressor.Options) (compressor.Compressor, error) {
+	if s != nil && strings.EqualFold(id, s.id) {
+		return compressor.SharedDictionary(s.dictionary)(options)
+	}
+	return compressor.DefaultRegistry.Create(id, options)
+}
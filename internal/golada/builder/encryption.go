@@ -0,0 +1,86 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package builder
+
+import (
+	"fmt"
+
+	"github.com/homeport/pina-golada/pkg/compressor"
+	"github.com/homeport/pina-golada/pkg/generator"
+)
+
+// DefaultKeyEnvironment is the environment variable that contains the hex encoded key of encrypted assets
+// if the interface annotation names neither a key file nor another environment variable
+const DefaultKeyEnvironment = "PINA_GOLADA_KEY"
+
+// encryptionKey returns the key of the encrypted assets, which is read from the key file of the interface
+// annotation or from its environment variable
+func (b Builder) encryptionKey() ([]byte, error) {
+	if len(b.interfaceAnnotation.KeyFile) > 0 {
+		return compressor.KeyFromFile(b.interfaceAnnotation.KeyFile)()
+	}
+
+	if len(b.interfaceAnnotation.KeyEnvironment) > 0 {
+		return compressor.KeyFromEnvironment(b.interfaceAnnotation.KeyEnvironment)()
+	}
+
+	return compressor.KeyFromEnvironment(DefaultKeyEnvironment)()
+}
+
+// encrypt seals the compressed assets of the method
+func (b Builder) encrypt(content []byte, methodName string) ([]byte, error) {
+	key, e := b.encryptionKey()
+	if e != nil {
+		return nil, fmt.Errorf("could not encrypt assets for %s: %w", methodName, e)
+	}
+
+	sealed, e := compressor.Seal(key, content)
+	if e != nil {
+		return nil, fmt.Errorf("could not encrypt assets for %s: %w", methodName, e)
+	}
+
+	b.logger.Debug("Gray{Debug➤ Encrypted assets for method} LimeGreen{%s}", b.target.Name.Name+"#"+methodName)
+	return sealed, nil
+}
+
+// generateDecryptMethod generates the InternalDecryptMethod, which decrypts assets using the
+// compressor.DefaultKeyProvider before they are decompressed
+func (b Builder) generateDecryptMethod(goGenerator generator.GoGenerator, receiverType string) {
+	goGenerator.Method(InternalDecryptMethod, func(method generator.MethodGenerator) {
+		method.Receiver(receiverType).
+			Parameters("methodName string", "compressorType string", "hexedContent string", "blobChecksum string",
+				"treeChecksum string").
+			ReturnTypes("files.Directory", "error").
+			Body([]string{
+				fmt.Sprintf("c := compressor.DefaultRegistry.Find(compressorType)"),
+				fmt.Sprintf(`if c == nil{return nil, fmt.Errorf("could not find compressor for %s", compressorType)}`, "%s"),
+				fmt.Sprintf(`decodedBytes , er := hex.DecodeString(hexedContent)`),
+				fmt.Sprintf(`if er != nil {return nil , compressor.NewIntegrityError("%s", methodName, "invalid hex content: " + er.Error())}`,
+					b.target.Name.Name),
+				fmt.Sprintf(`encrypted := compressor.NewEncrypted(c, compressor.DefaultKeyProvider)`),
+				fmt.Sprintf(`dir, er := compressor.NewChecked(encrypted, "%s", methodName, blobChecksum, treeChecksum).Decompress(context.Background(), bytes.NewReader(decodedBytes))`,
+					b.target.Name.Name),
+				fmt.Sprintf(`if er != nil {return nil, fmt.Errorf("could not provide encrypted assets of %s: %s", er)}`,
+					b.target.Name.Name, "%w"),
+				`return dir, nil`,
+			}...)
+	})
+}
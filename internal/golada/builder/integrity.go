@@ -0,0 +1,57 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package builder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/homeport/pina-golada/pkg/compressor"
+)
+
+// treeChecksum decompresses the compressed assets of the method the way the generated code does and returns
+// the checksum of the resulting tree. The tree of the Indexed compressor is not checked, as that would read all
+// of its lazily decompressed files, so its checksum is empty.
+func (b Builder) treeChecksum(compressorID string, compressed []byte, methodName string, shared *sharedDictionary) (string, error) {
+	instance, e := shared.create(compressorID, compressor.Options{})
+	if e != nil {
+		return "", fmt.Errorf("could not find compressor %s for %s: %w", compressorID, methodName, e)
+	}
+
+	if _, lazy := instance.(*compressor.Indexed); lazy {
+		b.logger.Debug("Gray{Debug➤ Skipped tree checksum of lazily decompressed assets for method} LimeGreen{%s}",
+			b.target.Name.Name+"#"+methodName)
+		return "", nil
+	}
+
+	directory, e := instance.Decompress(context.Background(), bytes.NewReader(compressed))
+	if e != nil {
+		return "", fmt.Errorf("could not decompress assets for %s: %w", methodName, e)
+	}
+
+	checksum, e := compressor.TreeChecksum(directory)
+	if e != nil {
+		return "", fmt.Errorf("could not compute checksum of the assets for %s: %w", methodName, e)
+	}
+
+	return checksum, nil
+}
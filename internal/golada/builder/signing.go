@@ -0,0 +1,91 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package builder
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/homeport/pina-golada/pkg/compressor"
+	"github.com/homeport/pina-golada/pkg/generator"
+)
+
+// signingKey returns the ed25519 key that signs the assets, which is read from the signing key file or
+// environment variable of the interface annotation. It returns nil if neither is set.
+func (b Builder) signingKey() (ed25519.PrivateKey, error) {
+	var encoded, source string
+	switch {
+	case len(b.interfaceAnnotation.SigningKeyFile) > 0:
+		source = "file " + b.interfaceAnnotation.SigningKeyFile
+		content, e := ioutil.ReadFile(b.interfaceAnnotation.SigningKeyFile)
+		if e != nil {
+			return nil, fmt.Errorf("could not read signing key of %s: %w", b.target.Name.Name, e)
+		}
+		encoded = string(content)
+
+	case len(b.interfaceAnnotation.SigningKeyEnvironment) > 0:
+		source = "environment variable " + b.interfaceAnnotation.SigningKeyEnvironment
+		encoded = os.Getenv(b.interfaceAnnotation.SigningKeyEnvironment)
+
+	default:
+		return nil, nil
+	}
+
+	key, e := compressor.DecodeSigningKey(encoded)
+	if e != nil {
+		return nil, fmt.Errorf("could not read signing key of %s from %s: %w", b.target.Name.Name, source, e)
+	}
+
+	return key, nil
+}
+
+// generateVerifyMethod generates the InternalVerifyMethod, which verifies the signature of assets against the
+// public key and the compressor.TrustedKeys before they are decompressed. Encrypted assets are decrypted first.
+func (b Builder) generateVerifyMethod(goGenerator generator.GoGenerator, receiverType string, publicKey ed25519.PublicKey) {
+	goGenerator.Import("crypto/ed25519")
+	goGenerator.Method(InternalVerifyMethod, func(method generator.MethodGenerator) {
+		method.Receiver(receiverType).
+			Parameters("methodName string", "compressorType string", "hexedSignature string", "hexedContent string",
+				"encrypted bool", "blobChecksum string", "treeChecksum string").
+			ReturnTypes("files.Directory", "error").
+			Body([]string{
+				fmt.Sprintf("c := compressor.DefaultRegistry.Find(compressorType)"),
+				fmt.Sprintf(`if c == nil{return nil, fmt.Errorf("could not find compressor for %s", compressorType)}`, "%s"),
+				fmt.Sprintf(`signature , er := hex.DecodeString(hexedSignature)`),
+				fmt.Sprintf(`if er != nil {return nil , compressor.NewIntegrityError("%s", methodName, "invalid hex signature: " + er.Error())}`,
+					b.target.Name.Name),
+				fmt.Sprintf(`decodedBytes , er := hex.DecodeString(hexedContent)`),
+				fmt.Sprintf(`if er != nil {return nil , compressor.NewIntegrityError("%s", methodName, "invalid hex content: " + er.Error())}`,
+					b.target.Name.Name),
+				fmt.Sprintf(`publicKey , er := compressor.DecodePublicKey("%x")`, []byte(publicKey)),
+				fmt.Sprintf(`if er != nil {return nil , er}`),
+				fmt.Sprintf(`var verified compressor.Compressor = compressor.NewVerified(c, signature, append([]ed25519.PublicKey{publicKey}, compressor.TrustedKeys...)...)`),
+				fmt.Sprintf(`if encrypted {verified = compressor.NewEncrypted(verified, compressor.DefaultKeyProvider)}`),
+				fmt.Sprintf(`dir, er := compressor.NewChecked(verified, "%s", methodName, blobChecksum, treeChecksum).Decompress(context.Background(), bytes.NewReader(decodedBytes))`,
+					b.target.Name.Name),
+				fmt.Sprintf(`if er != nil {return nil, fmt.Errorf("could not provide signed assets of %s: %s", er)}`,
+					b.target.Name.Name, "%w"),
+				`return dir, nil`,
+			}...)
+	})
+}
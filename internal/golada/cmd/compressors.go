@@ -0,0 +1,85 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/homeport/pina-golada/pkg/compressor"
+)
+
+var compressorsCommand = &cobra.Command{
+	Use:   "compressors",
+	Short: "Lists the available compressors",
+	Long:  "Lists the compressors that can be used in asset annotations, along with the extension and media type of their output and the archivers and codecs that can be combined to pipelines",
+	Run: func(c *cobra.Command, args []string) {
+		if err := ListCompressors(os.Stdout, compressor.DefaultRegistry); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// ListCompressors writes a table of the compressors of the registry into the writer
+func ListCompressors(writer io.Writer, registry compressor.Registry) error {
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(table, "ID\tALIASES\tEXTENSION\tMEDIA TYPE\tPERMISSIONS\tEMPTY DIRS\tDESCRIPTION")
+
+	for _, metadata := range registry.List() {
+		_, _ = fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", metadata.ID, orDash(strings.Join(metadata.Aliases, ", ")),
+			orDash(metadata.Extension), metadata.MediaType, yesNo(metadata.PreservesPermissions),
+			yesNo(metadata.PreservesEmptyDirectories), orDash(metadata.Description))
+	}
+
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(writer, "\nArchivers (%s) and codecs (%s) can be combined with a +, e.g. tar+zlib\n",
+		strings.Join(registry.Archivers(), ", "), strings.Join(registry.Codecs(), ", "))
+	return err
+}
+
+// orDash returns the value or a dash if it is empty
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// yesNo returns yes or no for the flag
+func yesNo(flag bool) string {
+	if flag {
+		return "yes"
+	}
+	return "no"
+}
+
+func init() {
+	rootCmd.AddCommand(compressorsCommand)
+}
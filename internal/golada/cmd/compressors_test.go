@@ -0,0 +1,63 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/compressor"
+)
+
+func TestCmd(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "pgl internal golada cmd")
+}
+
+var _ = Describe("should list the compressors", func() {
+
+	_ = It("should render the compressors of the default registry", func() {
+		output := &bytes.Buffer{}
+		Expect(ListCompressors(output, compressor.DefaultRegistry)).To(BeNil())
+
+		lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+		Expect(strings.Fields(lines[0])).To(Equal([]string{"ID", "ALIASES", "EXTENSION", "MEDIA", "TYPE",
+			"PERMISSIONS", "EMPTY", "DIRS", "DESCRIPTION"}))
+
+		rows := map[string][]string{}
+		for _, line := range lines[1:] {
+			if fields := strings.Fields(line); len(fields) > 0 {
+				rows[fields[0]] = fields
+			}
+		}
+		Expect(rows).To(HaveKey("tar"))
+		Expect(rows).To(HaveKey("zip"))
+		Expect(rows["store"][1]).To(Equal("none"))
+		Expect(rows["tar"][1]).To(Equal("-"))
+
+		Expect(lines[len(lines)-1]).To(Equal("Archivers (store, tar, zip) and codecs (flate, gzip, identity, none, " +
+			"pgzip, zlib) can be combined with a +, e.g. tar+zlib"))
+	})
+})
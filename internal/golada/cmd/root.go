@@ -19,7 +19,7 @@
 // THE SOFTWARE.
 
 // Package cmd hosts the different cobra commands pina-golada offers.
-// This package is home to the generate, version and cleanup command.
+// This package is home to the generate, version, compressors and cleanup command.
 package cmd
 
 import (
@@ -50,10 +50,13 @@ const (
 // Info logs the message on the info level
 //
 // Debug logs the message on the debug level
+//
+// Enabled returns if messages of the given log level are logged
 type Logger interface {
 	Log(level LogLevel, message string, a ...interface{})
 	Info(message string, a ...interface{})
 	Debug(message string, a ...interface{})
+	Enabled(level LogLevel) bool
 }
 
 // DefaultLogger defines the default logger implementation
@@ -69,7 +72,7 @@ func NewDefaultLogger(outputStream io.Writer, highestLogLevel LogLevel) *Default
 
 // Log logs the message with the given log level
 func (d *DefaultLogger) Log(level LogLevel, message string, a ...interface{}) {
-	if d.highestLogLevel >= level {
+	if d.Enabled(level) {
 		message := bunt.Sprintf(message, a...)
 		if !strings.HasSuffix(message, "\n") {
 			message += "\n"
@@ -88,3 +91,8 @@ func (d *DefaultLogger) Info(message string, a ...interface{}) {
 func (d *DefaultLogger) Debug(message string, a ...interface{}) {
 	d.Log(Debug, message, a...)
 }
+
+// Enabled returns if messages of the given log level are logged
+func (d *DefaultLogger) Enabled(level LogLevel) bool {
+	return d.highestLogLevel >= level
+}
@@ -24,15 +24,25 @@ package compressor
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
 )
 
 var (
 	// DefaultRegistry contains the registered compressors
-	DefaultRegistry = NewMapRegistry().Put("tar", &Tar{})
+	DefaultRegistry = NewMapRegistry().PutFactory("tar", NewTar).PutFactory("zip", NewZip).
+		PutFactory("store", NewStore).PutFactory("none", NewStore).PutFactory("indexed", NewIndexed).
+		PutFactory("dictionary", NewDictionary).PutFactory("parallel", NewParallel).
+		PutArchiver("tar", NewTarArchiver).PutArchiver("zip", NewZip).PutArchiver("store", NewStore).
+		PutCodec("gzip", NewGzip).PutCodec("zlib", NewZlib).PutCodec("flate", NewFlate).
+		PutCodec("identity", NewIdentity).PutCodec("none", NewIdentity).PutCodec("pgzip", NewParallelGzip)
 )
 
 // Registry contains a collection of Compressor instances that can be used to compress files
@@ -40,40 +50,346 @@ var (
 // Put stores a new compressor instance in the registry for the given id
 //
 // Find returns the compressor that is registered for the given id or nil if non was found
+//
+// PutFactory stores a factory in the registry that creates configured compressors for the given id
+//
+// Create returns a compressor for the given id that is configured by the options
+//
+// PutArchiver stores a factory of an archiver, which is combined with a codec by ids such as tar+zlib
+//
+// PutCodec stores a factory of a codec, which compresses the stream of an archiver
+//
+// IDs returns the sorted ids of all registered compressors
+//
+// Describe returns the metadata of the compressor for the given id, including pipelines
+//
+// List returns the metadata of all registered compressors, grouping the ids of a compressor as aliases
+//
+// Archivers and Codecs return the sorted ids that can be combined to pipelines
+//
+// Find and Create resolve ids that join an archiver and a codec with a plus sign into a Pipeline
 type Registry interface {
 	Put(id string, compressor Compressor) Registry
 	Find(id string) (compressor Compressor)
+
+	PutFactory(id string, factory Factory) Registry
+	Create(id string, options Options) (compressor Compressor, e error)
+
+	PutArchiver(id string, factory Factory) Registry
+	PutCodec(id string, factory CodecFactory) Registry
+
+	IDs() []string
+	Describe(id string) (metadata Metadata, e error)
+	List() []Metadata
+	Archivers() []string
+	Codecs() []string
 }
 
 // MapRegistry is a map based compressor registry
 type MapRegistry struct {
-	tracking map[string]Compressor
+	tracking  map[string]Compressor
+	factories map[string]Factory
+	archivers map[string]Factory
+	codecs    map[string]CodecFactory
 }
 
 // NewMapRegistry creates a new instance of the map registry
 func NewMapRegistry() *MapRegistry {
 	return &MapRegistry{
-		tracking: make(map[string]Compressor),
+		tracking:  make(map[string]Compressor),
+		factories: make(map[string]Factory),
+		archivers: make(map[string]Factory),
+		codecs:    make(map[string]CodecFactory),
 	}
 }
 
 // Put stores a new compressor instance for the given id in the registry
 func (r *MapRegistry) Put(id string, compressor Compressor) Registry {
+	delete(r.factories, strings.ToLower(id))
 	r.tracking[strings.ToLower(id)] = compressor
 	return r
 }
 
-// Find returns the found compressor instance for the given id, or nil if non was found
+// Find returns the found compressor instance for the given id, or nil if non was found.
+// Compressors registered using a factory and pipelines are created with empty options.
 func (r *MapRegistry) Find(id string) (compressor Compressor) {
+	if archiver, codec, ok := splitPipeline(id); ok {
+		compressor, e := r.createPipeline(archiver, codec, Options{})
+		if e != nil {
+			return nil
+		}
+		return compressor
+	}
+
+	if factory, ok := r.factories[strings.ToLower(id)]; ok {
+		compressor, e := factory(Options{})
+		if e != nil {
+			return nil
+		}
+		return compressor
+	}
+
 	return r.tracking[strings.ToLower(id)]
 }
 
+// PutFactory stores a new compressor factory for the given id in the registry
+func (r *MapRegistry) PutFactory(id string, factory Factory) Registry {
+	delete(r.tracking, strings.ToLower(id))
+	r.factories[strings.ToLower(id)] = factory
+	return r
+}
+
+// Create returns a compressor for the given id that is configured by the options. Compressors registered as
+// instance cannot be configured, they are only returned for empty options.
+func (r *MapRegistry) Create(id string, options Options) (compressor Compressor, e error) {
+	if archiver, codec, ok := splitPipeline(id); ok {
+		return r.createPipeline(archiver, codec, options)
+	}
+
+	if factory, ok := r.factories[strings.ToLower(id)]; ok {
+		return factory(options)
+	}
+
+	compressor, ok := r.tracking[strings.ToLower(id)]
+	if !ok {
+		return nil, fmt.Errorf("could not find compressor for %s", id)
+	}
+
+	if !options.IsZero() {
+		return nil, fmt.Errorf("compressor %s does not support any options", id)
+	}
+
+	return compressor, nil
+}
+
 // Compressor defines an object that is capable of compressing and decompressing a file
 type Compressor interface {
-	// Compress compresses the provided filepath into the output byte slice
+	// Compress compresses the directory into the writer. It stops with the error of the context once the
+	// context is done.
+	Compress(ctx context.Context, directory files.Directory, writer io.Writer) (e error)
 
-	Compress(directory files.Directory, writer *bytes.Buffer) (e error)
+	// Decompress decompresses the reader into a new root directory. It stops with the error of the context once
+	// the context is done.
+	Decompress(ctx context.Context, reader io.Reader) (dir files.Directory, e error)
+}
 
-	// Decompress decompresses the file paths into the output paths
+// LegacyCompressor is the buffer based compressor interface of earlier versions. Wrap implementations of it
+// using Adapt to register them.
+type LegacyCompressor interface {
+	Compress(directory files.Directory, writer *bytes.Buffer) (e error)
 	Decompress(reader io.Reader) (dir files.Directory, e error)
 }
+
+// Adapt wraps a legacy compressor so that it implements the Compressor interface. As the legacy compressor
+// cannot be interrupted, the context is only checked before and after it runs.
+func Adapt(legacy LegacyCompressor) Compressor {
+	return &legacyAdapter{legacy: legacy}
+}
+
+// legacyAdapter implements the Compressor interface using a LegacyCompressor
+type legacyAdapter struct {
+	legacy LegacyCompressor
+}
+
+// Metadata describes the legacy compressor if it implements the Describer interface
+func (a *legacyAdapter) Metadata() Metadata {
+	return MetadataOf(a.legacy)
+}
+
+// Compress compresses the directory into a buffer and copies it to the writer
+func (a *legacyAdapter) Compress(ctx context.Context, directory files.Directory, writer io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := a.legacy.Compress(directory, buffer); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := buffer.WriteTo(writer)
+	return err
+}
+
+// Decompress decompresses the reader using the legacy compressor
+func (a *legacyAdapter) Decompress(ctx context.Context, reader io.Reader) (files.Directory, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	directory, err := a.legacy.Decompress(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return directory, nil
+}
+
+// PutArchiver stores a new archiver factory for the given id in the registry
+func (r *MapRegistry) PutArchiver(id string, factory Factory) Registry {
+	r.archivers[strings.ToLower(id)] = factory
+	return r
+}
+
+// PutCodec stores a new codec factory for the given id in the registry
+func (r *MapRegistry) PutCodec(id string, factory CodecFactory) Registry {
+	r.codecs[strings.ToLower(id)] = factory
+	return r
+}
+
+// IDs returns the sorted ids of all registered compressors and factories, pipelines are not listed
+func (r *MapRegistry) IDs() []string {
+	ids := make([]string, 0, len(r.tracking)+len(r.factories))
+	for id := range r.tracking {
+		ids = append(ids, id)
+	}
+
+	for id := range r.factories {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// walkTree calls onDirectory for each sub directory and onFile for each file of the directory tree, stopping at
+// the first error returned by them or once the context is done. The entries of a directory are visited sorted by
+// name and every directory is visited before its content, so the order does not depend on how the tree was built.
+func walkTree(ctx context.Context, directory files.Directory, onDirectory func(d files.Directory) error,
+	onFile func(file files.File) error) error {
+	type entry struct {
+		name      string
+		file      files.File
+		directory files.Directory
+	}
+
+	var entries []entry
+	for _, file := range directory.Files() {
+		entries = append(entries, entry{name: file.Name().String(), file: file})
+	}
+
+	for _, dir := range directory.Directories() {
+		entries = append(entries, entry{name: dir.Name().String(), directory: dir})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if entry.file != nil {
+			if err := onFile(entry.file); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := onDirectory(entry.directory); err != nil {
+			return err
+		}
+
+		if err := walkTree(ctx, entry.directory, onDirectory, onFile); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// archiveReader reads the fields of an archive that is completely held in memory. Malformed fields are reported
+// wrapping the invalid error.
+type archiveReader struct {
+	data    []byte
+	offset  int
+	invalid error
+}
+
+// uvarint reads an unsigned varint
+func (r *archiveReader) uvarint() (uint64, error) {
+	value, length := binary.Uvarint(r.data[r.offset:])
+	if length <= 0 {
+		return 0, fmt.Errorf("%w: invalid number at offset %d", r.invalid, r.offset)
+	}
+
+	r.offset += length
+	return value, nil
+}
+
+// prefixed reads a uvarint length followed by as many bytes
+func (r *archiveReader) prefixed() ([]byte, error) {
+	length, e := r.uvarint()
+	if e != nil {
+		return nil, e
+	}
+
+	if length > uint64(len(r.data)-r.offset) {
+		return nil, fmt.Errorf("%w: %d bytes at offset %d exceed the archive", r.invalid, length, r.offset)
+	}
+
+	return r.next(int(length))
+}
+
+// path reads a length prefixed entry name, which has to be a path below the root
+func (r *archiveReader) path() (paths.Path, error) {
+	name, e := r.prefixed()
+	if e != nil {
+		return paths.Path{}, e
+	}
+
+	path, e := paths.Parse(string(name))
+	if e != nil {
+		return paths.Path{}, &entryNameError{invalid: r.invalid, name: string(name), err: e}
+	}
+
+	if !path.Valid() {
+		return paths.Path{}, fmt.Errorf("%w: invalid entry name %q", r.invalid, name)
+	}
+
+	return path, nil
+}
+
+// entryNameError is returned for entry names that cannot be parsed. It matches the invalid error of the archive
+// and wraps the error of the parser, e.g. paths.ErrOutsideRoot.
+type entryNameError struct {
+	invalid error
+	name    string
+	err     error
+}
+
+// Error returns a description of the error that names the entry
+func (e *entryNameError) Error() string {
+	return fmt.Sprintf("%v: invalid entry name %q: %v", e.invalid, e.name, e.err)
+}
+
+// Is matches the invalid error of the archive
+func (e *entryNameError) Is(target error) bool {
+	return target == e.invalid
+}
+
+// Unwrap returns the error of the parser
+func (e *entryNameError) Unwrap() error {
+	return e.err
+}
+
+// next returns the next bytes of the archive
+func (r *archiveReader) next(length int) ([]byte, error) {
+	if length > len(r.data)-r.offset {
+		return nil, fmt.Errorf("%w: unexpected end of archive", r.invalid)
+	}
+
+	result := r.data[r.offset : r.offset+length]
+	r.offset += length
+	return result, nil
+}
@@ -0,0 +1,168 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/homeport/pina-golada/pkg/files"
+)
+
+// ErrIntegrity is matched by every IntegrityError
+var ErrIntegrity = errors.New("integrity check failed")
+
+// IntegrityError is returned when embedded assets do not match their checksums, e.g. because the generated file
+// was edited or truncated
+type IntegrityError struct {
+	Interface string
+	Method    string
+	Reason    string
+}
+
+// NewIntegrityError creates an integrity error of the assets provided by the method of the interface
+func NewIntegrityError(interfaceName string, methodName string, reason string) *IntegrityError {
+	return &IntegrityError{Interface: interfaceName, Method: methodName, Reason: reason}
+}
+
+// Error returns a description of the error that names the interface and method
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("integrity check of the assets of %s#%s failed, the generated file may have been "+
+		"edited or truncated: %s", e.Interface, e.Method, e.Reason)
+}
+
+// Is matches ErrIntegrity
+func (e *IntegrityError) Is(target error) bool {
+	return target == ErrIntegrity
+}
+
+// Checksum returns the hex encoded SHA-256 checksum of the blob
+func Checksum(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	return hex.EncodeToString(sum[:])
+}
+
+// TreeChecksum returns the hex encoded SHA-256 checksum of the paths, permissions and contents of all entries
+// below the directory. It does not depend on the order in which the entries were added.
+func TreeChecksum(directory files.Directory) (string, error) {
+	hash := sha256.New()
+	writeEntry := func(kind byte, path string, mode uint32, content []byte) {
+		header := make([]byte, 1, 1+3*binary.MaxVarintLen64+len(path))
+		header[0] = kind
+		header = append(header, encodeUvarint(uint64(len(path)))...)
+		header = append(header, path...)
+		header = append(header, encodeUvarint(uint64(mode))...)
+		header = append(header, encodeUvarint(uint64(len(content)))...)
+		_, _ = hash.Write(header)
+		_, _ = hash.Write(content)
+	}
+
+	if err := walkTree(context.Background(), directory, func(d files.Directory) error {
+		writeEntry('d', d.AbsolutePath().String(), uint32(d.PermissionSet().Perm()), nil)
+		return nil
+	}, func(file files.File) error {
+		buffer := &bytes.Buffer{}
+		if err := file.CopyContent(buffer); err != nil {
+			return err
+		}
+
+		writeEntry('f', file.AbsolutePath().String(), uint32(file.PermissionSet().Perm()), buffer.Bytes())
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// encodeUvarint returns the varint encoding of the value
+func encodeUvarint(value uint64) []byte {
+	buffer := make([]byte, binary.MaxVarintLen64)
+	return buffer[:binary.PutUvarint(buffer, value)]
+}
+
+// Checked is a compressor that verifies the checksum of the blob before the inner compressor decompresses it
+// and the checksum of the decompressed tree afterwards. Failures are reported as IntegrityError of the method
+// of the interface. The tree is not verified if its checksum is empty, e.g. to keep files of the Indexed compressor
+// from being read right away. Compress does not compute checksums, the builder stores them next to the blob.
+type Checked struct {
+	Inner     Compressor
+	Interface string
+	Method    string
+	Blob      string
+	Tree      string
+}
+
+// NewChecked creates a compressor that verifies the blob and tree checksums of the assets of the method
+func NewChecked(inner Compressor, interfaceName string, methodName string, blob string, tree string) *Checked {
+	return &Checked{Inner: inner, Interface: interfaceName, Method: methodName, Blob: blob, Tree: tree}
+}
+
+// Metadata describes the inner compressor, as the checksums are not part of its output
+func (c *Checked) Metadata() Metadata {
+	return MetadataOf(c.Inner)
+}
+
+// Compress compresses the directory using the inner compressor
+func (c *Checked) Compress(ctx context.Context, directory files.Directory, writer io.Writer) error {
+	return c.Inner.Compress(ctx, directory, writer)
+}
+
+// Decompress verifies the checksum of the blob, decompresses it and verifies the checksum of the tree
+func (c *Checked) Decompress(ctx context.Context, reader io.Reader) (files.Directory, error) {
+	blob, e := ioutil.ReadAll(reader)
+	if e != nil {
+		return nil, e
+	}
+
+	if checksum := Checksum(blob); checksum != c.Blob {
+		return nil, NewIntegrityError(c.Interface, c.Method, fmt.Sprintf(
+			"the %d compressed bytes have the checksum %s instead of %s", len(blob), checksum, c.Blob))
+	}
+
+	directory, e := c.Inner.Decompress(ctx, bytes.NewReader(blob))
+	if e != nil {
+		return nil, e
+	}
+
+	if len(c.Tree) == 0 {
+		return directory, nil
+	}
+
+	checksum, e := TreeChecksum(directory)
+	if e != nil {
+		return nil, e
+	}
+
+	if checksum != c.Tree {
+		return nil, NewIntegrityError(c.Interface, c.Method, fmt.Sprintf(
+			"the decompressed files have the checksum %s instead of %s", checksum, c.Tree))
+	}
+
+	return directory, nil
+}
@@ -0,0 +1,98 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should check the integrity of assets", func() {
+	var (
+		directory files.Directory
+		blob      []byte
+		tree      string
+	)
+
+	_ = BeforeEach(func() {
+		directory = files.NewRootDirectory()
+		Expect(directory.NewFile(paths.Of("usr/homeport/a.txt")).Write(bytes.NewBufferString("a"))).To(BeNil())
+		Expect(directory.NewFile(paths.Of("usr/b.txt")).Write(bytes.NewBufferString("b"))).To(BeNil())
+
+		buffer := &bytes.Buffer{}
+		Expect((&Tar{}).Compress(context.Background(), directory, buffer)).To(BeNil())
+		blob = buffer.Bytes()
+
+		var e error
+		tree, e = TreeChecksum(directory)
+		Expect(e).To(BeNil())
+	})
+
+	_ = It("should decompress assets that match their checksums", func() {
+		result, e := NewChecked(&Tar{}, "Assets", "GetAssets", Checksum(blob), tree).
+			Decompress(context.Background(), bytes.NewReader(blob))
+		Expect(e).To(BeNil())
+		Expect(result.File(paths.Of("usr/b.txt"))).ToNot(BeNil())
+	})
+
+	_ = It("should report truncated blobs naming the interface and method", func() {
+		_, e := NewChecked(&Tar{}, "Assets", "GetAssets", Checksum(blob), tree).
+			Decompress(context.Background(), bytes.NewReader(blob[:len(blob)/2]))
+		Expect(errors.Is(e, ErrIntegrity)).To(BeTrue())
+		Expect(e.Error()).To(ContainSubstring("Assets#GetAssets"))
+	})
+
+	_ = It("should report trees that do not match their checksum", func() {
+		_, e := NewChecked(&Tar{}, "Assets", "GetAssets", Checksum(blob), Checksum([]byte("other"))).
+			Decompress(context.Background(), bytes.NewReader(blob))
+
+		var integrityError *IntegrityError
+		Expect(errors.As(e, &integrityError)).To(BeTrue())
+		Expect(integrityError.Method).To(BeEquivalentTo("GetAssets"))
+
+		result, e := NewChecked(&Tar{}, "Assets", "GetAssets", Checksum(blob), "").
+			Decompress(context.Background(), bytes.NewReader(blob))
+		Expect(e).To(BeNil())
+		Expect(result).ToNot(BeNil())
+	})
+
+	_ = It("should compute tree checksums independent of the insertion order", func() {
+		other := files.NewRootDirectory()
+		Expect(other.NewFile(paths.Of("usr/b.txt")).Write(bytes.NewBufferString("b"))).To(BeNil())
+		Expect(other.NewFile(paths.Of("usr/homeport/a.txt")).Write(bytes.NewBufferString("a"))).To(BeNil())
+
+		checksum, e := TreeChecksum(other)
+		Expect(e).To(BeNil())
+		Expect(checksum).To(Equal(tree))
+
+		Expect(other.NewFile(paths.Of("usr/b.txt")).Write(bytes.NewBufferString("c"))).To(BeNil())
+		checksum, e = TreeChecksum(other)
+		Expect(e).To(BeNil())
+		Expect(checksum).ToNot(Equal(tree))
+	})
+})
@@ -0,0 +1,206 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Codec compresses the byte stream of an archive. Archivers and codecs are combined to a Pipeline.
+type Codec interface {
+	// Writer returns a writer that encodes into the writer, it has to be closed to flush the stream
+	Writer(writer io.Writer) (io.WriteCloser, error)
+
+	// Reader returns a reader that decodes the reader
+	Reader(reader io.Reader) (io.ReadCloser, error)
+}
+
+// CodecFactory creates a codec that is configured by the options
+type CodecFactory func(options Options) (Codec, error)
+
+// Gzip is a codec that encodes streams in the gzip format. The header is normalized, so the output does not
+// depend on the host or time. Level is the compression level, flate.BestCompression is used if it is zero.
+type Gzip struct {
+	Level int
+}
+
+// NewGzip creates a gzip codec. Only the compression level can be configured.
+func NewGzip(options Options) (Codec, error) {
+	level, err := codecLevel("gzip", options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Gzip{Level: level}, nil
+}
+
+// Metadata describes the gzip codec
+func (g *Gzip) Metadata() Metadata {
+	return Metadata{ID: "gzip", Description: "gzip stream", Extension: ".gz", MediaType: "application/gzip"}
+}
+
+// Writer returns a gzip writer with a normalized header
+func (g *Gzip) Writer(writer io.Writer) (io.WriteCloser, error) {
+	level, err := Options{Level: g.Level}.flateLevel(flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	gzipWriter, err := gzip.NewWriterLevel(writer, level)
+	if err != nil {
+		return nil, err
+	}
+
+	gzipWriter.Header = gzip.Header{OS: gzipUnknownOS} // Keep the header independent of the host and time
+	return gzipWriter, nil
+}
+
+// Reader returns a gzip reader
+func (g *Gzip) Reader(reader io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(reader)
+}
+
+// Zlib is a codec that encodes streams in the zlib format. Level is the compression level,
+// flate.BestCompression is used if it is zero.
+type Zlib struct {
+	Level int
+}
+
+// NewZlib creates a zlib codec. Only the compression level can be configured.
+func NewZlib(options Options) (Codec, error) {
+	level, err := codecLevel("zlib", options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Zlib{Level: level}, nil
+}
+
+// Metadata describes the zlib codec
+func (z *Zlib) Metadata() Metadata {
+	return Metadata{ID: "zlib", Description: "zlib stream", Extension: ".zz", MediaType: "application/zlib"}
+}
+
+// Writer returns a zlib writer
+func (z *Zlib) Writer(writer io.Writer) (io.WriteCloser, error) {
+	level, err := Options{Level: z.Level}.flateLevel(flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	return zlib.NewWriterLevel(writer, level)
+}
+
+// Reader returns a zlib reader
+func (z *Zlib) Reader(reader io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(reader)
+}
+
+// Flate is a codec that encodes streams as raw deflate data without header or checksum. Level is the
+// compression level, flate.BestCompression is used if it is zero.
+type Flate struct {
+	Level int
+}
+
+// NewFlate creates a flate codec. Only the compression level can be configured.
+func NewFlate(options Options) (Codec, error) {
+	level, err := codecLevel("flate", options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Flate{Level: level}, nil
+}
+
+// Metadata describes the flate codec
+func (f *Flate) Metadata() Metadata {
+	return Metadata{ID: "flate", Description: "raw deflate stream", Extension: ".deflate", MediaType: mediaTypeBinary}
+}
+
+// Writer returns a flate writer
+func (f *Flate) Writer(writer io.Writer) (io.WriteCloser, error) {
+	level, err := Options{Level: f.Level}.flateLevel(flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	return flate.NewWriter(writer, level)
+}
+
+// Reader returns a flate reader
+func (f *Flate) Reader(reader io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(reader), nil
+}
+
+// Identity is a codec that passes streams through unchanged
+type Identity struct{}
+
+// NewIdentity creates an identity codec, it cannot be configured
+func NewIdentity(options Options) (Codec, error) {
+	if options.Level != 0 {
+		return nil, fmt.Errorf("codec identity does not support a compression level")
+	}
+
+	if err := options.checkKeys("identity"); err != nil {
+		return nil, err
+	}
+
+	return &Identity{}, nil
+}
+
+// Metadata describes the identity codec
+func (Identity) Metadata() Metadata {
+	return Metadata{ID: "identity", Description: "unencoded stream"}
+}
+
+// Writer returns the writer, closing it does not close the writer
+func (Identity) Writer(writer io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{writer}, nil
+}
+
+// Reader returns the reader, closing it does not close the reader
+func (Identity) Reader(reader io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(reader), nil
+}
+
+// nopWriteCloser is a writer whose Close method does nothing
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close does nothing
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// codecLevel validates the options of a codec that only supports a flate compression level
+func codecLevel(codec string, options Options) (int, error) {
+	if err := options.checkKeys(codec); err != nil {
+		return 0, err
+	}
+
+	return options.flateLevel(flate.BestCompression)
+}
@@ -0,0 +1,301 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/homeport/pina-golada/pkg/files"
+)
+
+const (
+	// dictionaryMagic starts every archive written by the Dictionary compressor, followed by the format version
+	dictionaryMagic   = "PGLD"
+	dictionaryVersion = 1
+
+	// The flags of the Dictionary format
+	dictionaryEmbedded byte = 1
+
+	// MaxDictionarySize is the size of the deflate window, preset dictionaries are never used beyond it
+	MaxDictionarySize = 32 * 1024
+)
+
+var (
+	// ErrInvalidDictionaryArchive is returned when the input of Dictionary.Decompress is not a valid archive
+	ErrInvalidDictionaryArchive = errors.New("invalid dictionary archive")
+
+	// ErrMissingDictionary is returned when an archive was compressed with a shared dictionary, but the
+	// Dictionary compressor does not know it
+	ErrMissingDictionary = errors.New("archive requires a shared dictionary that is not available")
+)
+
+// Dictionary is an implementation of the compressor interface which deflates the Store format using a preset
+// dictionary. The dictionary is built off of the content of the files, or shared across archives, so small and
+// similar files, like JSON or YAML documents, compress well even if every archive only contains a few of them.
+//
+// Level is the deflate compression level, flate.BestCompression is used if it is zero. If Dict is set, it is
+// used as shared dictionary, which is not stored in the archive and has to be known when decompressing, see
+// SharedDictionary. Otherwise a dictionary of Size bytes is built and embedded. As the deflate stream already
+// refers back to earlier files, this only helps archives larger than MaxDictionarySize, so Size defaults to no
+// dictionary at all.
+//
+// The archive starts with "PGLD", a version byte and a flags byte. If the dictionary is embedded, it follows as
+// uvarint length prefixed bytes. The rest is the Store archive of the directory, deflated with the dictionary.
+type Dictionary struct {
+	Level int
+	Size  int
+	Dict  []byte
+}
+
+// NewDictionary creates a dictionary compressor. The compression level and the size of the dictionary can be
+// configured using the size option. Without the size option, no dictionary is embedded, see Dictionary.
+func NewDictionary(options Options) (Compressor, error) {
+	if err := options.checkKeys("dictionary", "size"); err != nil {
+		return nil, err
+	}
+
+	level, err := options.flateLevel(flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	size := 0
+	if value, ok := options.Values["size"]; ok {
+		if size, err = strconv.Atoi(value); err != nil || size < 1 || size > MaxDictionarySize {
+			return nil, fmt.Errorf("invalid dictionary size %s, expected 1 to %d", value, MaxDictionarySize)
+		}
+	}
+
+	return &Dictionary{Level: level, Size: size}, nil
+}
+
+// SharedDictionary returns a factory of dictionary compressors that use the shared dictionary
+func SharedDictionary(dictionary []byte) Factory {
+	return func(options Options) (Compressor, error) {
+		compressor, err := NewDictionary(options)
+		if err != nil {
+			return nil, err
+		}
+
+		compressor.(*Dictionary).Dict = dictionary
+		return compressor, nil
+	}
+}
+
+// BuildDictionary builds a preset dictionary of at most size bytes off of the samples. It prefers lines that
+// occur in many samples and puts the most valuable ones at the end, where deflate references them cheapest.
+// Samples without common lines contribute their beginnings instead.
+func BuildDictionary(samples [][]byte, size int) []byte {
+	if size <= 0 || size > MaxDictionarySize {
+		size = MaxDictionarySize
+	}
+
+	occurrences := map[string]int{}
+	for _, sample := range samples {
+		seen := map[string]bool{}
+		for _, line := range bytes.SplitAfter(sample, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) > 0 && !seen[string(line)] {
+				seen[string(line)] = true
+				occurrences[string(line)]++
+			}
+		}
+	}
+
+	var common []string
+	for line, count := range occurrences {
+		if count > 1 {
+			common = append(common, line)
+		}
+	}
+
+	sort.Slice(common, func(i, j int) bool {
+		scoreI, scoreJ := occurrences[common[i]]*len(common[i]), occurrences[common[j]]*len(common[j])
+		if scoreI != scoreJ {
+			return scoreI > scoreJ
+		}
+		return common[i] < common[j]
+	})
+
+	var selected []string
+	remaining := size
+	for _, line := range common {
+		if len(line) <= remaining {
+			selected = append(selected, line)
+			remaining -= len(line)
+		}
+	}
+
+	dictionary := make([]byte, 0, size)
+	perSample := 0
+	if len(samples) > 0 {
+		perSample = remaining/len(samples) + 1
+	}
+
+	for _, sample := range samples { // Fill the start, which is least valuable, with the beginnings of the samples
+		share := perSample
+		if share > len(sample) {
+			share = len(sample)
+		}
+		if share > remaining {
+			share = remaining
+		}
+
+		dictionary = append(dictionary, sample[:share]...)
+		remaining -= share
+	}
+
+	for index := len(selected) - 1; index >= 0; index-- {
+		dictionary = append(dictionary, selected[index]...)
+	}
+
+	return dictionary
+}
+
+// Metadata describes the dictionary compressor
+func (d *Dictionary) Metadata() Metadata {
+	return Metadata{
+		ID:                        "dictionary",
+		Description:               "deflated store archive using a preset dictionary",
+		Extension:                 ".pgld",
+		MediaType:                 mediaTypeBinary,
+		PreservesPermissions:      true,
+		PreservesEmptyDirectories: true,
+	}
+}
+
+// Compress compresses the directory into the writer
+func (d *Dictionary) Compress(ctx context.Context, directory files.Directory, writer io.Writer) error {
+	level, err := Options{Level: d.Level}.flateLevel(flate.BestCompression)
+	if err != nil {
+		return err
+	}
+
+	dictionary, flags := d.Dict, byte(0)
+	if dictionary == nil {
+		dictionary, flags = []byte{}, dictionaryEmbedded
+	}
+
+	if d.Dict == nil && d.Size > 0 {
+		var samples [][]byte
+		if err := walkTree(ctx, directory, func(files.Directory) error { return nil }, func(file files.File) error {
+			content := &bytes.Buffer{}
+			if err := file.CopyContent(content); err != nil {
+				return err
+			}
+
+			samples = append(samples, content.Bytes())
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		dictionary = BuildDictionary(samples, d.Size)
+	}
+
+	output := bufio.NewWriter(writer)
+	if _, err := output.WriteString(dictionaryMagic); err != nil {
+		return err
+	}
+
+	if _, err := output.Write([]byte{dictionaryVersion, flags}); err != nil {
+		return err
+	}
+
+	if flags&dictionaryEmbedded != 0 {
+		if err := writeUvarint(output, uint64(len(dictionary))); err != nil {
+			return err
+		}
+
+		if _, err := output.Write(dictionary); err != nil {
+			return err
+		}
+	}
+
+	flateWriter, err := flate.NewWriterDict(output, level, dictionary)
+	if err != nil {
+		return err
+	}
+
+	if err := (&Store{}).Compress(ctx, directory, flateWriter); err != nil {
+		return err
+	}
+
+	if err := flateWriter.Close(); err != nil {
+		return err
+	}
+
+	return output.Flush()
+}
+
+// Decompress decompresses the reader into a new root directory
+func (d *Dictionary) Decompress(ctx context.Context, reader io.Reader) (files.Directory, error) {
+	buffered := bufio.NewReader(reader)
+
+	header := make([]byte, len(dictionaryMagic)+2)
+	if _, err := io.ReadFull(buffered, header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDictionaryArchive, err)
+	}
+
+	if string(header[:len(dictionaryMagic)]) != dictionaryMagic {
+		return nil, fmt.Errorf("%w: missing magic bytes", ErrInvalidDictionaryArchive)
+	}
+
+	if header[len(dictionaryMagic)] != dictionaryVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidDictionaryArchive, header[len(dictionaryMagic)])
+	}
+
+	dictionary := d.Dict
+	if header[len(dictionaryMagic)+1]&dictionaryEmbedded != 0 {
+		size, err := binary.ReadUvarint(buffered)
+		if err != nil || size > MaxDictionarySize {
+			return nil, fmt.Errorf("%w: invalid dictionary size", ErrInvalidDictionaryArchive)
+		}
+
+		dictionary = make([]byte, size)
+		if _, err := io.ReadFull(buffered, dictionary); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDictionaryArchive, err)
+		}
+	} else if dictionary == nil {
+		return nil, ErrMissingDictionary
+	}
+
+	flateReader := flate.NewReaderDict(buffered, dictionary)
+	defer flateReader.Close()
+
+	directory, err := (&Store{}).Decompress(ctx, flateReader)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDictionaryArchive, err)
+	}
+
+	return directory, nil
+}
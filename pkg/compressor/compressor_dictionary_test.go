@@ -0,0 +1,255 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+// smallDocuments creates a directory with the amount of small, similar JSON and YAML documents
+func smallDocuments(count int) files.Directory {
+	directory := files.NewRootDirectory()
+	for i := 0; i < count; i++ {
+		json := fmt.Sprintf("{\n  \"apiVersion\": \"v1\",\n  \"kind\": \"ConfigMap\",\n  \"metadata\": {\n    \"name\": \"config-%d\",\n"+
+			"    \"namespace\": \"default\"\n  },\n  \"data\": {\n    \"replicas\": \"%d\"\n  }\n}\n", i, i%5)
+		yaml := fmt.Sprintf("apiVersion: v1\nkind: Service\nmetadata:\n  name: service-%d\n  namespace: default\n"+
+			"spec:\n  ports:\n  - port: %d\n    protocol: TCP\n", i, 8000+i)
+
+		_ = directory.NewFile(paths.Of(fmt.Sprintf("configs/config-%d.json", i))).Write(bytes.NewBufferString(json))
+		_ = directory.NewFile(paths.Of(fmt.Sprintf("services/service-%d.yml", i))).Write(bytes.NewBufferString(yaml))
+	}
+
+	return directory
+}
+
+// compressedSize returns the size of the directory compressed by the compressor
+func compressedSize(compressor Compressor, directory files.Directory) (int, error) {
+	buffer := &bytes.Buffer{}
+	err := compressor.Compress(context.Background(), directory, buffer)
+	return buffer.Len(), err
+}
+
+var _ = Describe("should compress with a preset dictionary", func() {
+	var directory files.Directory
+
+	_ = BeforeEach(func() {
+		directory = smallDocuments(100)
+	})
+
+	_ = It("should round trip with an embedded dictionary", func() {
+		buffer := &bytes.Buffer{}
+		Expect((&Dictionary{}).Compress(context.Background(), directory, buffer)).To(BeNil())
+
+		result, e := (&Dictionary{}).Decompress(context.Background(), buffer)
+		Expect(e).To(BeNil())
+
+		expected, e := TreeChecksum(directory)
+		Expect(e).To(BeNil())
+		Expect(TreeChecksum(result)).To(Equal(expected))
+	})
+
+	_ = It("should need the shared dictionary to decompress", func() {
+		shared, e := SharedDictionary([]byte("\"apiVersion\": \"v1\",\n"))(Options{})
+		Expect(e).To(BeNil())
+
+		buffer := &bytes.Buffer{}
+		Expect(shared.Compress(context.Background(), directory, buffer)).To(BeNil())
+
+		_, e = (&Dictionary{}).Decompress(context.Background(), bytes.NewReader(buffer.Bytes()))
+		Expect(errors.Is(e, ErrMissingDictionary)).To(BeTrue())
+
+		result, e := shared.Decompress(context.Background(), bytes.NewReader(buffer.Bytes()))
+		Expect(e).To(BeNil())
+		Expect(result.File(paths.Of("configs/config-1.json"))).ToNot(BeNil())
+	})
+
+	_ = It("should be smaller than tar and embed dictionaries on request", func() {
+		plainSize, e := compressedSize(&Dictionary{}, directory)
+		Expect(e).To(BeNil())
+
+		tarSize, e := compressedSize(&Tar{}, directory)
+		Expect(e).To(BeNil())
+		Expect(plainSize).To(BeNumerically("<", tarSize))
+
+		embedded, e := NewDictionary(Options{Values: map[string]string{"size": "1024"}})
+		Expect(e).To(BeNil())
+
+		buffer := &bytes.Buffer{}
+		Expect(embedded.Compress(context.Background(), directory, buffer)).To(BeNil())
+		Expect(buffer.Len()).To(BeNumerically(">", plainSize))
+
+		result, e := (&Dictionary{}).Decompress(context.Background(), buffer)
+		Expect(e).To(BeNil())
+		Expect(result.File(paths.Of("services/service-1.yml"))).ToNot(BeNil())
+	})
+
+	_ = It("should share the dictionary across small archives", func() {
+		var samples [][]byte
+		files.WalkFileTree(directory, func(file files.File) {
+			content := &bytes.Buffer{}
+			Expect(file.CopyContent(content)).To(BeNil())
+			samples = append(samples, content.Bytes())
+		})
+
+		shared, e := SharedDictionary(BuildDictionary(samples, MaxDictionarySize))(Options{})
+		Expect(e).To(BeNil())
+
+		single := smallDocuments(1)
+		sharedSize, e := compressedSize(shared, single)
+		Expect(e).To(BeNil())
+
+		tarSize, e := compressedSize(&Tar{}, single)
+		Expect(e).To(BeNil())
+
+		Expect(sharedSize).To(BeNumerically("<", tarSize/2))
+	})
+
+	_ = It("should build reproducible dictionaries of limited size", func() {
+		samples := [][]byte{[]byte("a: 1\nb: 2\n"), []byte("a: 1\nc: 3\n"), []byte("a: 1\nb: 2\n")}
+
+		dictionary := BuildDictionary(samples, 64)
+		Expect(len(dictionary)).To(BeNumerically("<=", 64))
+		Expect(bytes.HasSuffix(dictionary, []byte("a: 1\n"))).To(BeTrue())
+		Expect(BuildDictionary(samples, 64)).To(Equal(dictionary))
+
+		Expect(BuildDictionary(nil, 64)).To(BeEmpty())
+	})
+
+	_ = It("should reject invalid options and archives", func() {
+		_, e := NewDictionary(Options{Values: map[string]string{"size": "0"}})
+		Expect(e).ToNot(BeNil())
+
+		_, e = NewDictionary(Options{Values: map[string]string{"size": "1024"}})
+		Expect(e).To(BeNil())
+
+		buffer := &bytes.Buffer{}
+		Expect((&Dictionary{}).Compress(context.Background(), directory, buffer)).To(BeNil())
+
+		_, e = (&Dictionary{}).Decompress(context.Background(), bytes.NewReader(buffer.Bytes()[:buffer.Len()/2]))
+		Expect(errors.Is(e, ErrInvalidDictionaryArchive)).To(BeTrue())
+	})
+})
+
+// BenchmarkDictionary reports the compressed size of the test fixtures and of many small documents next to the
+// time it takes to compress them, and the size relative to the tar compressor. The documents are compressed as a
+// whole and one by one, like assets of many methods, which is where the shared dictionary pays off.
+func BenchmarkDictionary(b *testing.B) {
+	fixtures := files.NewRootDirectory()
+	if err := files.LoadFromDisk(fixtures, "../../assets/tests"); err != nil {
+		b.Fatal(err)
+	}
+
+	documents := smallDocuments(200)
+	var samples [][]byte
+	files.WalkFileTree(documents, func(file files.File) {
+		content := &bytes.Buffer{}
+		if err := file.CopyContent(content); err != nil {
+			b.Fatal(err)
+		}
+		samples = append(samples, content.Bytes())
+	})
+
+	shared, err := SharedDictionary(BuildDictionary(samples, MaxDictionarySize))(Options{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defaults, err := DefaultRegistry.Create("dictionary", Options{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	compressors := []struct {
+		name       string
+		compressor Compressor
+	}{
+		{"tar", &Tar{}},
+		{"indexed", &Indexed{}},
+		{"dictionary", defaults},
+		{"embedded-dictionary", &Dictionary{Size: MaxDictionarySize}},
+		{"shared-dictionary", shared},
+	}
+
+	for _, set := range []struct {
+		name        string
+		directories []files.Directory
+	}{
+		{"fixtures", []files.Directory{fixtures}},
+		{"documents", []files.Directory{documents}},
+		{"single-documents", singleDocuments(documents)},
+	} {
+		tarTotal, err := totalSize(&Tar{}, set.directories)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, entry := range compressors {
+			set, entry := set, entry
+			b.Run(set.name+"/"+entry.name, func(b *testing.B) {
+				var total int
+				var err error
+				for i := 0; i < b.N; i++ {
+					if total, err = totalSize(entry.compressor, set.directories); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(total), "size")
+				b.ReportMetric(100*float64(total)/float64(tarTotal), "%tar")
+			})
+		}
+	}
+}
+
+// totalSize returns the sum of the compressed sizes of the directories
+func totalSize(compressor Compressor, directories []files.Directory) (int, error) {
+	var total int
+	for _, directory := range directories {
+		size, err := compressedSize(compressor, directory)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// singleDocuments returns a directory for every file of the directory
+func singleDocuments(directory files.Directory) []files.Directory {
+	var result []files.Directory
+	files.WalkFileTree(directory, func(file files.File) {
+		single := files.NewRootDirectory()
+		content := &bytes.Buffer{}
+		_ = file.CopyContent(content)
+		_ = single.NewFile(file.AbsolutePath()).Write(content)
+		result = append(result, single)
+	})
+	return result
+}
@@ -0,0 +1,239 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/homeport/pina-golada/pkg/files"
+)
+
+const (
+	// encryptedMagic starts every payload sealed by Seal, followed by the format version
+	encryptedMagic   = "PGLE"
+	encryptedVersion = 1
+
+	// KeySize is the size of the AES-256 keys in bytes
+	KeySize = 32
+)
+
+var (
+	// EncryptionKey is the hex encoded key that the DefaultKeyProvider returns. It is meant to be set when linking,
+	// e.g. go build -ldflags "-X github.com/homeport/pina-golada/pkg/compressor.EncryptionKey=<key>".
+	EncryptionKey string
+
+	// DefaultKeyProvider provides the key of encrypted assets at runtime. It returns the EncryptionKey, replace it
+	// to provide the key in another way.
+	DefaultKeyProvider KeyProvider = func() ([]byte, error) {
+		return DecodeKey(EncryptionKey)
+	}
+
+	// ErrNoKey is returned when no encryption key is available
+	ErrNoKey = errors.New("no encryption key available, set compressor.DefaultKeyProvider or inject " +
+		"compressor.EncryptionKey using -ldflags")
+
+	// ErrInvalidKey is returned for keys that are not hex encoded AES-256 keys
+	ErrInvalidKey = fmt.Errorf("invalid encryption key, expected %d hex encoded bytes", KeySize)
+
+	// ErrDecryptionFailed is returned when a payload was not sealed with the key or was modified
+	ErrDecryptionFailed = errors.New("could not decrypt assets, the key is wrong or the assets were modified")
+)
+
+// KeyProvider provides the AES-256 key used to encrypt and decrypt assets
+type KeyProvider func() (key []byte, e error)
+
+// DecodeKey decodes a hex encoded AES-256 key, e.g. one created by openssl rand -hex 32
+func DecodeKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if len(encoded) == 0 {
+		return nil, ErrNoKey
+	}
+
+	key, e := hex.DecodeString(encoded)
+	if e != nil || len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	return key, nil
+}
+
+// KeyFromEnvironment returns a key provider that decodes the key of the environment variable
+func KeyFromEnvironment(name string) KeyProvider {
+	return func() ([]byte, error) {
+		key, e := DecodeKey(os.Getenv(name))
+		if e != nil {
+			return nil, fmt.Errorf("could not read key from environment variable %s: %w", name, e)
+		}
+		return key, nil
+	}
+}
+
+// KeyFromFile returns a key provider that decodes the key stored in the file
+func KeyFromFile(path string) KeyProvider {
+	return func() ([]byte, error) {
+		content, e := ioutil.ReadFile(path)
+		if e != nil {
+			return nil, fmt.Errorf("could not read key from file %s: %w", path, e)
+		}
+
+		key, e := DecodeKey(string(content))
+		if e != nil {
+			return nil, fmt.Errorf("could not read key from file %s: %w", path, e)
+		}
+		return key, nil
+	}
+}
+
+// Encrypted is a compressor that seals the output of the inner compressor using AES-256-GCM
+type Encrypted struct {
+	Inner Compressor
+	Key   KeyProvider
+}
+
+// NewEncrypted creates a compressor that encrypts the output of the inner compressor with the provided key
+func NewEncrypted(inner Compressor, key KeyProvider) *Encrypted {
+	return &Encrypted{Inner: inner, Key: key}
+}
+
+// Metadata describes the inner compressor, whose output is sealed into the binary .pgle format
+func (c *Encrypted) Metadata() Metadata {
+	metadata := MetadataOf(c.Inner)
+	metadata.Extension += ".pgle"
+	metadata.MediaType = mediaTypeBinary
+	if metadata.Description != "" {
+		metadata.Description = "encrypted " + metadata.Description
+	}
+
+	return metadata
+}
+
+// Compress compresses the directory using the inner compressor and writes the sealed output into the writer
+func (c *Encrypted) Compress(ctx context.Context, directory files.Directory, writer io.Writer) error {
+	key, e := c.Key()
+	if e != nil {
+		return e
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := c.Inner.Compress(ctx, directory, buffer); err != nil {
+		return err
+	}
+
+	sealed, e := Seal(key, buffer.Bytes())
+	if e != nil {
+		return e
+	}
+
+	_, e = writer.Write(sealed)
+	return e
+}
+
+// Decompress opens the sealed reader and decompresses it using the inner compressor
+func (c *Encrypted) Decompress(ctx context.Context, reader io.Reader) (files.Directory, error) {
+	key, e := c.Key()
+	if e != nil {
+		return nil, e
+	}
+
+	sealed, e := ioutil.ReadAll(reader)
+	if e != nil {
+		return nil, e
+	}
+
+	plain, e := Open(key, sealed)
+	if e != nil {
+		return nil, e
+	}
+
+	return c.Inner.Decompress(ctx, bytes.NewReader(plain))
+}
+
+// Seal encrypts and authenticates the plain text using AES-256-GCM. The nonce is derived from the key and the
+// plain text, so sealing is reproducible and a nonce is only used twice for the same plain text.
+func Seal(key []byte, plain []byte) ([]byte, error) {
+	aead, e := newAEAD(key)
+	if e != nil {
+		return nil, e
+	}
+
+	header := append([]byte(encryptedMagic), encryptedVersion)
+
+	nonceKey := hmac.New(sha256.New, key)
+	_, _ = nonceKey.Write([]byte("pina-golada nonce"))
+	nonceMac := hmac.New(sha256.New, nonceKey.Sum(nil))
+	_, _ = nonceMac.Write(plain)
+	nonce := nonceMac.Sum(nil)[:aead.NonceSize()]
+
+	sealed := append(append([]byte{}, header...), nonce...)
+	return aead.Seal(sealed, nonce, plain, header), nil
+}
+
+// Open decrypts a payload created by Seal
+func Open(key []byte, sealed []byte) ([]byte, error) {
+	aead, e := newAEAD(key)
+	if e != nil {
+		return nil, e
+	}
+
+	headerSize := len(encryptedMagic) + 1
+	if len(sealed) < headerSize+aead.NonceSize() || string(sealed[:len(encryptedMagic)]) != encryptedMagic {
+		return nil, ErrDecryptionFailed
+	}
+
+	if sealed[len(encryptedMagic)] != encryptedVersion {
+		return nil, fmt.Errorf("unsupported encryption version %d", sealed[len(encryptedMagic)])
+	}
+
+	header := sealed[:headerSize]
+	nonce := sealed[headerSize : headerSize+aead.NonceSize()]
+	plain, e := aead.Open(nil, nonce, sealed[headerSize+aead.NonceSize():], header)
+	if e != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return plain, nil
+}
+
+// newAEAD creates the AES-256-GCM cipher of the key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	block, e := aes.NewCipher(key)
+	if e != nil {
+		return nil, e
+	}
+
+	return cipher.NewGCM(block)
+}
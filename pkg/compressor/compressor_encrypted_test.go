@@ -0,0 +1,141 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should encrypt assets", func() {
+	var (
+		directory files.Directory
+		key       []byte
+	)
+
+	staticKey := func(key []byte) KeyProvider {
+		return func() ([]byte, error) { return key, nil }
+	}
+
+	_ = BeforeEach(func() {
+		directory = files.NewRootDirectory()
+		Expect(directory.NewFile(paths.Of("certs/internal.pem")).Write(bytes.NewBufferString("secret certificate"))).To(BeNil())
+		key = bytes.Repeat([]byte{7}, KeySize)
+	})
+
+	_ = It("should round trip and hide the content", func() {
+		compressor := NewEncrypted(&Store{}, staticKey(key))
+
+		buffer := &bytes.Buffer{}
+		Expect(compressor.Compress(context.Background(), directory, buffer)).To(BeNil())
+		Expect(buffer.String()).ToNot(ContainSubstring("secret certificate"))
+		Expect(buffer.String()).ToNot(ContainSubstring("internal.pem"))
+
+		result, e := compressor.Decompress(context.Background(), buffer)
+		Expect(e).To(BeNil())
+
+		content := &bytes.Buffer{}
+		Expect(result.File(paths.Of("certs/internal.pem")).CopyContent(content)).To(BeNil())
+		Expect(content.String()).To(BeEquivalentTo("secret certificate"))
+	})
+
+	_ = It("should seal reproducibly", func() {
+		first, e := Seal(key, []byte("assets"))
+		Expect(e).To(BeNil())
+		second, e := Seal(key, []byte("assets"))
+		Expect(e).To(BeNil())
+		other, e := Seal(key, []byte("other assets"))
+		Expect(e).To(BeNil())
+
+		Expect(first).To(Equal(second))
+		Expect(first[5:17]).ToNot(Equal(other[5:17]))
+	})
+
+	_ = It("should reject wrong keys and modified payloads", func() {
+		sealed, e := Seal(key, []byte("assets"))
+		Expect(e).To(BeNil())
+
+		_, e = Open(bytes.Repeat([]byte{8}, KeySize), sealed)
+		Expect(errors.Is(e, ErrDecryptionFailed)).To(BeTrue())
+
+		sealed[len(sealed)-1] ^= 1
+		_, e = Open(key, sealed)
+		Expect(errors.Is(e, ErrDecryptionFailed)).To(BeTrue())
+
+		_, e = Open(key, []byte("PGLE"))
+		Expect(errors.Is(e, ErrDecryptionFailed)).To(BeTrue())
+	})
+
+	_ = It("should report missing and invalid keys", func() {
+		original := EncryptionKey
+		defer func() { EncryptionKey = original }()
+
+		EncryptionKey = ""
+		_, e := NewEncrypted(&Store{}, DefaultKeyProvider).Decompress(context.Background(), &bytes.Buffer{})
+		Expect(errors.Is(e, ErrNoKey)).To(BeTrue())
+
+		EncryptionKey = "abcd"
+		_, e = DefaultKeyProvider()
+		Expect(errors.Is(e, ErrInvalidKey)).To(BeTrue())
+
+		EncryptionKey = hex.EncodeToString(key)
+		decoded, e := DefaultKeyProvider()
+		Expect(e).To(BeNil())
+		Expect(decoded).To(Equal(key))
+	})
+
+	_ = It("should read keys from the environment and files", func() {
+		Expect(os.Setenv("PINA_GOLADA_TEST_KEY", hex.EncodeToString(key))).To(BeNil())
+		defer os.Unsetenv("PINA_GOLADA_TEST_KEY")
+
+		decoded, e := KeyFromEnvironment("PINA_GOLADA_TEST_KEY")()
+		Expect(e).To(BeNil())
+		Expect(decoded).To(Equal(key))
+
+		_, e = KeyFromEnvironment("PINA_GOLADA_TEST_MISSING_KEY")()
+		Expect(errors.Is(e, ErrNoKey)).To(BeTrue())
+
+		dir, e := ioutil.TempDir("", "pina-golada-key")
+		Expect(e).To(BeNil())
+		defer os.RemoveAll(dir)
+
+		keyFile := filepath.Join(dir, "key")
+		Expect(ioutil.WriteFile(keyFile, []byte(hex.EncodeToString(key)+"\n"), 0600)).To(BeNil())
+
+		decoded, e = KeyFromFile(keyFile)()
+		Expect(e).To(BeNil())
+		Expect(decoded).To(Equal(key))
+
+		_, e = KeyFromFile(filepath.Join(dir, "missing"))()
+		Expect(e).ToNot(BeNil())
+	})
+})
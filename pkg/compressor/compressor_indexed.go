@@ -0,0 +1,380 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+const (
+	// indexedMagic starts and ends every archive written by the Indexed compressor
+	indexedMagic   = "PGLI"
+	indexedVersion = 1
+
+	// indexedFooterSize is the size of the footer, the offset of the index followed by the magic bytes
+	indexedFooterSize = 8 + len(indexedMagic)
+
+	// The compression methods of file entries
+	indexedStored   = 0
+	indexedDeflated = 1
+
+	// maxDeflateRatio is the highest ratio deflate can reach, which limits the size a file entry may declare
+	maxDeflateRatio = 1032
+)
+
+var (
+	// ErrInvalidIndexedArchive is returned when the input of Indexed.Decompress is not a valid archive
+	ErrInvalidIndexedArchive = errors.New("invalid indexed archive")
+)
+
+// Indexed is an implementation of the compressor interface which compresses every file on its own and keeps a
+// central index of all entries. Decompressing only reads the index, the returned directory decompresses the
+// content of a file when it is accessed for the first time. Readers that implement io.ReaderAt and provide their
+// size, like bytes.Reader, are used in place without copying them.
+//
+// Level is the deflate compression level, flate.BestCompression is used if it is zero. Files that do not get
+// smaller are stored without compression.
+//
+// The archive starts with "PGLI" and a version byte, followed by the contents of the files. The index lists the
+// uvarint permission set of the root and every directory and file as entry type byte, uvarint length prefixed
+// path and uvarint permission set. Files add the compression method, offset, compressed size and size as
+// uvarints. A zero byte ends the index. The archive ends with the little endian 64 bit offset of the index and
+// "PGLI".
+type Indexed struct {
+	Level int
+}
+
+// NewIndexed creates an indexed compressor. Only the compression level can be configured.
+func NewIndexed(options Options) (Compressor, error) {
+	if err := options.checkKeys("indexed"); err != nil {
+		return nil, err
+	}
+
+	level, err := options.flateLevel(flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Indexed{Level: level}, nil
+}
+
+// Metadata describes the indexed compressor
+func (i *Indexed) Metadata() Metadata {
+	return Metadata{
+		ID:                        "indexed",
+		Description:               "files compressed on their own and decompressed when read",
+		Extension:                 ".pgli",
+		MediaType:                 mediaTypeBinary,
+		PreservesPermissions:      true,
+		PreservesEmptyDirectories: true,
+	}
+}
+
+// Compress compresses the directory into the writer
+func (i *Indexed) Compress(ctx context.Context, directory files.Directory, writer io.Writer) error {
+	level, err := Options{Level: i.Level}.flateLevel(flate.BestCompression)
+	if err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriter(writer)
+	output := &offsetWriter{writer: buffered}
+
+	if _, err := io.WriteString(output, indexedMagic); err != nil {
+		return err
+	}
+
+	if _, err := output.Write([]byte{indexedVersion}); err != nil {
+		return err
+	}
+
+	index := &bytes.Buffer{}
+	if err := writeUvarint(index, uint64(directory.PermissionSet())); err != nil {
+		return err
+	}
+
+	if err := walkTree(ctx, directory, func(d files.Directory) error {
+		return writeStoreHeader(index, storeDirectory, d.AbsolutePath(), d.PermissionSet())
+	}, func(file files.File) error {
+		content := &bytes.Buffer{}
+		if err := file.CopyContent(content); err != nil {
+			return err
+		}
+
+		method, data, err := compressEntry(content.Bytes(), level)
+		if err != nil {
+			return err
+		}
+
+		offset := output.offset
+		if _, err := output.Write(data); err != nil {
+			return err
+		}
+
+		if err := writeStoreHeader(index, storeFile, file.AbsolutePath(), file.PermissionSet()); err != nil {
+			return err
+		}
+
+		for _, value := range []uint64{method, uint64(offset), uint64(len(data)), uint64(content.Len())} {
+			if err := writeUvarint(index, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	index.WriteByte(storeEnd)
+
+	footer := make([]byte, indexedFooterSize)
+	binary.LittleEndian.PutUint64(footer, uint64(output.offset))
+	copy(footer[8:], indexedMagic)
+
+	if _, err := index.WriteTo(output); err != nil {
+		return err
+	}
+
+	if _, err := output.Write(footer); err != nil {
+		return err
+	}
+
+	return buffered.Flush()
+}
+
+// compressEntry deflates the content, or stores it if deflating does not make it smaller
+func compressEntry(content []byte, level int) (method uint64, data []byte, e error) {
+	compressed := &bytes.Buffer{}
+	flateWriter, e := flate.NewWriter(compressed, level)
+	if e != nil {
+		return 0, nil, e
+	}
+
+	if _, e := flateWriter.Write(content); e != nil {
+		return 0, nil, e
+	}
+
+	if e := flateWriter.Close(); e != nil {
+		return 0, nil, e
+	}
+
+	if compressed.Len() >= len(content) {
+		return indexedStored, content, nil
+	}
+
+	return indexedDeflated, compressed.Bytes(), nil
+}
+
+// Decompress reads the index of the archive into a directory whose files are decompressed on first access
+func (i *Indexed) Decompress(ctx context.Context, reader io.Reader) (directory files.Directory, e error) {
+	source, size, e := readerAt(reader)
+	if e != nil {
+		return nil, e
+	}
+
+	headerSize := int64(len(indexedMagic) + 1)
+	if size < headerSize+int64(indexedFooterSize) {
+		return nil, fmt.Errorf("%w: archive is too small", ErrInvalidIndexedArchive)
+	}
+
+	header := make([]byte, headerSize)
+	footer := make([]byte, indexedFooterSize)
+	if _, err := source.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+
+	if _, err := source.ReadAt(footer, size-int64(indexedFooterSize)); err != nil {
+		return nil, err
+	}
+
+	if string(header[:len(indexedMagic)]) != indexedMagic || string(footer[8:]) != indexedMagic {
+		return nil, fmt.Errorf("%w: missing magic bytes", ErrInvalidIndexedArchive)
+	}
+
+	if header[len(indexedMagic)] != indexedVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidIndexedArchive, header[len(indexedMagic)])
+	}
+
+	indexOffset := int64(binary.LittleEndian.Uint64(footer))
+	if indexOffset < headerSize || indexOffset > size-int64(indexedFooterSize) {
+		return nil, fmt.Errorf("%w: index offset %d is out of range", ErrInvalidIndexedArchive, indexOffset)
+	}
+
+	index := make([]byte, size-int64(indexedFooterSize)-indexOffset)
+	if _, err := source.ReadAt(index, indexOffset); err != nil {
+		return nil, err
+	}
+
+	input := &archiveReader{data: index, invalid: ErrInvalidIndexedArchive}
+	rootMode, e := input.uvarint()
+	if e != nil {
+		return nil, e
+	}
+
+	root := files.NewRootDirectory()
+	root.WithPermission(os.FileMode(rootMode))
+
+	entries := indexedEntries{source: source, root: root, start: headerSize, end: indexOffset}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		entryType, err := input.next(1)
+		if err != nil {
+			return nil, err
+		}
+
+		if entryType[0] == storeEnd {
+			return root, nil
+		}
+
+		if err := entries.read(input, entryType[0]); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// indexedEntries creates the entries of an index in the root directory
+type indexedEntries struct {
+	source io.ReaderAt
+	root   files.Directory
+	start  int64
+	end    int64
+}
+
+// read reads the entry of the type from the index into the root directory
+func (i *indexedEntries) read(input *archiveReader, entryType byte) error {
+	path, e := input.path()
+	if e != nil {
+		return e
+	}
+
+	mode, e := input.uvarint()
+	if e != nil {
+		return e
+	}
+
+	switch entryType {
+	case storeDirectory:
+		i.root.NewDirectory(path).WithPermission(os.FileMode(mode))
+		return nil
+
+	case storeFile:
+		var values [4]uint64
+		for index := range values {
+			if values[index], e = input.uvarint(); e != nil {
+				return e
+			}
+		}
+
+		loader, e := i.loader(path, values[0], values[1], values[2], values[3])
+		if e != nil {
+			return e
+		}
+
+		file, e := files.NewLazyFile(i.root, path, loader)
+		if e != nil {
+			return e
+		}
+
+		file.WithPermission(os.FileMode(mode))
+		return nil
+	}
+
+	return fmt.Errorf("%w: unknown entry type %d", ErrInvalidIndexedArchive, entryType)
+}
+
+// loader returns the content loader of a file entry after checking that the entry is located in the data section
+func (i *indexedEntries) loader(path paths.Path, method uint64, offset uint64, compressedSize uint64, size uint64) (files.ContentLoader, error) {
+	if offset < uint64(i.start) || offset > uint64(i.end) || compressedSize > uint64(i.end)-offset {
+		return nil, fmt.Errorf("%w: content of %s is out of range", ErrInvalidIndexedArchive, path.String())
+	}
+
+	switch {
+	case method == indexedStored && size != compressedSize,
+		method == indexedDeflated && size > compressedSize*maxDeflateRatio,
+		method != indexedStored && method != indexedDeflated:
+		return nil, fmt.Errorf("%w: invalid size or compression method of %s", ErrInvalidIndexedArchive, path.String())
+	}
+
+	source := i.source
+	return func() ([]byte, error) {
+		var reader io.Reader = io.NewSectionReader(source, int64(offset), int64(compressedSize))
+		if method == indexedDeflated {
+			flateReader := flate.NewReader(reader)
+			defer flateReader.Close()
+			reader = flateReader
+		}
+
+		content := make([]byte, size)
+		if _, err := io.ReadFull(reader, content); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidIndexedArchive, err)
+		}
+
+		return content, nil
+	}, nil
+}
+
+// sizedReaderAt is a reader with random access that knows its size, like bytes.Reader
+type sizedReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// readerAt returns the reader if it allows random access or reads it into memory otherwise
+func readerAt(reader io.Reader) (io.ReaderAt, int64, error) {
+	if sized, ok := reader.(sizedReaderAt); ok {
+		return sized, sized.Size(), nil
+	}
+
+	data, e := ioutil.ReadAll(reader)
+	if e != nil {
+		return nil, 0, e
+	}
+
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+// offsetWriter tracks the number of bytes written to the writer
+type offsetWriter struct {
+	writer io.Writer
+	offset int64
+}
+
+// Write writes to the writer and advances the offset
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, e := o.writer.Write(p)
+	o.offset += int64(n)
+	return n, e
+}
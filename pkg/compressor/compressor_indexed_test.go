@@ -0,0 +1,146 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+// recordingReaderAt records the ranges that are read from a byte slice
+type recordingReaderAt struct {
+	*bytes.Reader
+	reads [][2]int64
+}
+
+// ReadAt reads from the byte slice and records the range
+func (r *recordingReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	r.reads = append(r.reads, [2]int64{offset, offset + int64(len(p))})
+	return r.Reader.ReadAt(p, offset)
+}
+
+var _ = Describe("should compress indexed archives correctly", func() {
+	var (
+		directory files.Directory
+		buffer    *bytes.Buffer
+	)
+
+	contentOf := func(ref files.Directory, path string) string {
+		file := ref.File(paths.Of(path))
+		Expect(file).ToNot(BeNil())
+
+		content := &bytes.Buffer{}
+		Expect(file.CopyContent(content)).To(BeNil())
+		return content.String()
+	}
+
+	_ = BeforeEach(func() {
+		directory = files.NewRootDirectory()
+		buffer = &bytes.Buffer{}
+
+		for i := 0; i < 10; i++ {
+			content := strings.Repeat(fmt.Sprintf("template %d\n", i), 200)
+			Expect(directory.NewFile(paths.Of(fmt.Sprintf("templates/%d.tmpl", i))).WithPermission(0640).
+				Write(bytes.NewBufferString(content))).To(BeNil())
+		}
+		Expect(directory.NewFile(paths.Of("tiny.txt")).WithPermission(0600).Write(bytes.NewBufferString("a"))).To(BeNil())
+		directory.NewDirectory(paths.Of("empty")).WithPermission(os.ModeDir | 0700)
+	})
+
+	_ = It("should be registered in the default registry", func() {
+		Expect(DefaultRegistry.Find("indexed")).ToNot(BeNil())
+	})
+
+	_ = It("should restore files, permissions and empty directories", func() {
+		Expect((&Indexed{}).Compress(context.Background(), directory, buffer)).To(BeNil())
+
+		result, e := (&Indexed{}).Decompress(context.Background(), bytes.NewReader(buffer.Bytes()))
+		Expect(e).To(BeNil())
+
+		Expect(contentOf(result, "templates/3.tmpl")).To(BeEquivalentTo(contentOf(directory, "templates/3.tmpl")))
+		Expect(contentOf(result, "tiny.txt")).To(BeEquivalentTo("a"))
+		Expect(result.File(paths.Of("templates/3.tmpl")).PermissionSet()).To(BeEquivalentTo(0640))
+		Expect(result.Directory(paths.Of("empty")).PermissionSet().Perm()).To(BeEquivalentTo(0700))
+	})
+
+	_ = It("should only read the index and the accessed file", func() {
+		Expect((&Indexed{}).Compress(context.Background(), directory, buffer)).To(BeNil())
+		reader := &recordingReaderAt{Reader: bytes.NewReader(buffer.Bytes())}
+
+		result, e := (&Indexed{}).Decompress(context.Background(), reader)
+		Expect(e).To(BeNil())
+
+		indexOffset := int64(binary.LittleEndian.Uint64(buffer.Bytes()[buffer.Len()-indexedFooterSize:]))
+		indexReads := len(reader.reads)
+		for _, read := range reader.reads {
+			Expect(read[1] <= 5 || read[0] >= indexOffset).To(BeTrue()) // Header, index and footer only
+		}
+
+		Expect(contentOf(result, "templates/7.tmpl")).To(BeEquivalentTo(contentOf(directory, "templates/7.tmpl")))
+		Expect(len(reader.reads) - indexReads).To(BeNumerically(">", 0))
+
+		dataRead := int64(0)
+		for _, read := range reader.reads[indexReads:] {
+			dataRead += read[1] - read[0]
+		}
+		Expect(dataRead).To(BeNumerically("<", (indexOffset-5)/5))
+
+		reads := len(reader.reads)
+		Expect(contentOf(result, "templates/7.tmpl")).ToNot(BeEmpty())
+		Expect(len(reader.reads)).To(BeEquivalentTo(reads)) // The content is only decompressed once
+	})
+
+	_ = It("should report corrupt content when the file is accessed", func() {
+		Expect((&Indexed{}).Compress(context.Background(), directory, buffer)).To(BeNil())
+		corrupted := buffer.Bytes()
+		for i := 5; i < 25; i++ {
+			corrupted[i] ^= 0xff
+		}
+
+		result, e := (&Indexed{}).Decompress(context.Background(), bytes.NewReader(corrupted))
+		Expect(e).To(BeNil())
+
+		e = result.File(paths.Of("templates/0.tmpl")).CopyContent(&bytes.Buffer{})
+		Expect(errors.Is(e, ErrInvalidIndexedArchive)).To(BeTrue())
+	})
+
+	_ = It("should reject invalid archives", func() {
+		_, e := (&Indexed{}).Decompress(context.Background(), bytes.NewBufferString("PGLI"))
+		Expect(errors.Is(e, ErrInvalidIndexedArchive)).To(BeTrue())
+
+		Expect((&Indexed{}).Compress(context.Background(), directory, buffer)).To(BeNil())
+		truncated := buffer.Bytes()[:buffer.Len()-1]
+		_, e = (&Indexed{}).Decompress(context.Background(), bytes.NewReader(truncated))
+		Expect(errors.Is(e, ErrInvalidIndexedArchive)).To(BeTrue())
+	})
+
+})
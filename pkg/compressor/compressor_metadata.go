@@ -0,0 +1,153 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Metadata describes a compressor or codec and the format of its output
+//
+// ID is the canonical id of the compressor, Aliases are the other ids it is registered for
+//
+// Extension is the file extension of the output including the leading dot, e.g. ".tar.gz"
+//
+// MediaType is the MIME type of the output, e.g. "application/gzip"
+//
+// PreservesPermissions and PreservesEmptyDirectories report whether decompressing restores the permission sets
+// and empty directories of the compressed tree
+type Metadata struct {
+	ID          string
+	Aliases     []string
+	Description string
+
+	Extension string
+	MediaType string
+
+	PreservesPermissions      bool
+	PreservesEmptyDirectories bool
+}
+
+// Describer is implemented by compressors and codecs that describe themselves
+type Describer interface {
+	Metadata() Metadata
+}
+
+// mediaTypeBinary is the media type of formats that have no registered media type
+const mediaTypeBinary = "application/octet-stream"
+
+// MetadataOf returns the metadata of the compressor or codec, or an empty Metadata if it does not implement
+// the Describer interface
+func MetadataOf(described interface{}) Metadata {
+	if describer, ok := described.(Describer); ok {
+		return describer.Metadata()
+	}
+
+	return Metadata{}
+}
+
+// Describe returns the metadata of the compressor registered for the id, including its aliases. Compressors
+// that do not describe themselves are reported with the id and the binary media type.
+func (r *MapRegistry) Describe(id string) (Metadata, error) {
+	compressor := r.Find(id)
+	if compressor == nil {
+		return Metadata{}, fmt.Errorf("could not find compressor for %s", id)
+	}
+
+	metadata := r.describe(strings.ToLower(strings.TrimSpace(id)), compressor)
+	if _, _, ok := splitPipeline(id); ok {
+		return metadata, nil
+	}
+
+	for _, other := range r.List() {
+		if other.ID == metadata.ID {
+			return other, nil
+		}
+	}
+
+	return metadata, nil
+}
+
+// List returns the metadata of all registered compressors sorted by id. Ids that are registered for the same
+// kind of compressor, like none for store, are listed as aliases of its canonical id. Pipelines are not listed.
+func (r *MapRegistry) List() []Metadata {
+	var list []Metadata
+	index := map[string]int{}
+	for _, id := range r.IDs() {
+		compressor := r.Find(id)
+		if compressor == nil {
+			continue
+		}
+
+		metadata := r.describe(id, compressor)
+		if _, ok := index[metadata.ID]; !ok {
+			index[metadata.ID] = len(list)
+			list = append(list, metadata)
+		}
+
+		if id != metadata.ID {
+			existing := &list[index[metadata.ID]]
+			existing.Aliases = append(existing.Aliases, id)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Archivers returns the sorted ids of the archivers that can be combined with the Codecs to a pipeline
+func (r *MapRegistry) Archivers() []string {
+	ids := make([]string, 0, len(r.archivers))
+	for id := range r.archivers {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// Codecs returns the sorted ids of the codecs that can be combined with the Archivers to a pipeline
+func (r *MapRegistry) Codecs() []string {
+	ids := make([]string, 0, len(r.codecs))
+	for id := range r.codecs {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// describe returns the metadata of the compressor, filling in the id and media type if it does not describe
+// itself
+func (r *MapRegistry) describe(id string, compressor Compressor) Metadata {
+	metadata := MetadataOf(compressor)
+	if metadata.ID == "" {
+		metadata.ID = id
+	}
+
+	if metadata.MediaType == "" {
+		metadata.MediaType = mediaTypeBinary
+	}
+
+	return metadata
+}
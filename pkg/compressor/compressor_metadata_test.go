@@ -0,0 +1,94 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("should describe compressors", func() {
+	_ = It("should describe the built-in compressors", func() {
+		metadata, e := DefaultRegistry.Describe("TAR")
+		Expect(e).To(BeNil())
+		Expect(metadata.ID).To(Equal("tar"))
+		Expect(metadata.Extension).To(Equal(".tar.gz"))
+		Expect(metadata.MediaType).To(Equal("application/gzip"))
+		Expect(metadata.PreservesPermissions).To(BeTrue())
+		Expect(metadata.PreservesEmptyDirectories).To(BeTrue())
+
+		metadata, e = DefaultRegistry.Describe("zip")
+		Expect(e).To(BeNil())
+		Expect(metadata.Extension).To(Equal(".zip"))
+		Expect(metadata.MediaType).To(Equal("application/zip"))
+
+		_, e = DefaultRegistry.Describe("unknown")
+		Expect(e).ToNot(BeNil())
+	})
+
+	_ = It("should list aliases with their canonical id", func() {
+		metadata, e := DefaultRegistry.Describe("none")
+		Expect(e).To(BeNil())
+		Expect(metadata.ID).To(Equal("store"))
+		Expect(metadata.Aliases).To(Equal([]string{"none"}))
+
+		var ids []string
+		for _, metadata := range NewMapRegistry().PutFactory("tar", NewTar).PutFactory("none", NewStore).
+			PutFactory("store", NewStore).List() {
+			ids = append(ids, metadata.ID)
+		}
+		Expect(ids).To(Equal([]string{"store", "tar"}))
+	})
+
+	_ = It("should combine the metadata of pipelines", func() {
+		metadata, e := DefaultRegistry.Describe("tar+zlib")
+		Expect(e).To(BeNil())
+		Expect(metadata.ID).To(Equal("tar+zlib"))
+		Expect(metadata.Extension).To(Equal(".tar.zz"))
+		Expect(metadata.MediaType).To(Equal("application/zlib"))
+
+		metadata, e = DefaultRegistry.Describe("zip+none")
+		Expect(e).To(BeNil())
+		Expect(metadata.ID).To(Equal("zip+identity"))
+		Expect(metadata.Extension).To(Equal(".zip"))
+		Expect(metadata.MediaType).To(Equal("application/zip"))
+
+		Expect(DefaultRegistry.Archivers()).To(Equal([]string{"store", "tar", "zip"}))
+		Expect(DefaultRegistry.Codecs()).To(ContainElements("gzip", "none", "pgzip"))
+	})
+
+	_ = It("should describe compressors that do not describe themselves by their id", func() {
+		registry := NewMapRegistry().Put("legacy", Adapt(legacyStore{}))
+
+		metadata, e := registry.Describe("legacy")
+		Expect(e).To(BeNil())
+		Expect(metadata).To(Equal(Metadata{ID: "legacy", MediaType: "application/octet-stream"}))
+	})
+
+	_ = It("should describe wrapped compressors", func() {
+		encrypted := NewEncrypted(&Tar{}, nil).Metadata()
+		Expect(encrypted.ID).To(Equal("tar"))
+		Expect(encrypted.Extension).To(Equal(".tar.gz.pgle"))
+		Expect(encrypted.MediaType).To(Equal("application/octet-stream"))
+
+		Expect(NewChecked(&Zip{}, "", "", "", "").Metadata()).To(Equal((&Zip{}).Metadata()))
+	})
+})
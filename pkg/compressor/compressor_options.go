@@ -0,0 +1,110 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"compress/flate"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// optionSeparator separates the key value pairs of an options string
+	optionSeparator = "+"
+
+	// optionAssignment separates the key from the value of an option
+	optionAssignment = ":"
+)
+
+// Options configures a compressor instance that is created by a Factory
+//
+// Level is the compression level from 1, which is the fastest, to 9, which compresses best. Zero selects the
+// default level of the compressor.
+//
+// Values contains compressor specific settings.
+type Options struct {
+	Level  int
+	Values map[string]string
+}
+
+// Factory creates a compressor instance that is configured by the options
+type Factory func(options Options) (Compressor, error)
+
+// ParseOptions creates options off of the level and a string of compressor specific key value pairs, which are
+// written as key:value and separated by plus signs, e.g. "store:.png|.jpg+comment:assets".
+func ParseOptions(level int, values string) (Options, error) {
+	options := Options{Level: level, Values: map[string]string{}}
+	for _, pair := range strings.Split(values, optionSeparator) {
+		if len(strings.TrimSpace(pair)) == 0 {
+			continue
+		}
+
+		keyToValue := strings.SplitN(pair, optionAssignment, 2)
+		if len(keyToValue) != 2 || len(strings.TrimSpace(keyToValue[0])) == 0 {
+			return Options{}, fmt.Errorf("invalid compressor option %q, expected key%svalue", pair, optionAssignment)
+		}
+
+		options.Values[strings.ToLower(strings.TrimSpace(keyToValue[0]))] = strings.TrimSpace(keyToValue[1])
+	}
+
+	return options, nil
+}
+
+// IsZero returns if the options do not configure anything
+func (o Options) IsZero() bool {
+	return o.Level == 0 && len(o.Values) == 0
+}
+
+// flateLevel returns the flate compression level of the options, or the default level if no level is set
+func (o Options) flateLevel(defaultLevel int) (int, error) {
+	if o.Level == 0 {
+		return defaultLevel, nil
+	}
+
+	if o.Level < flate.BestSpeed || o.Level > flate.BestCompression {
+		return 0, fmt.Errorf("invalid compression level %d, expected %d to %d", o.Level, flate.BestSpeed, flate.BestCompression)
+	}
+
+	return o.Level, nil
+}
+
+// checkKeys returns an error if the options contain values for keys that are not allowed
+func (o Options) checkKeys(compressor string, allowed ...string) error {
+	var unknown []string
+	for key := range o.Values {
+		found := false
+		for _, candidate := range allowed {
+			found = found || candidate == key
+		}
+
+		if !found {
+			unknown = append(unknown, key)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("compressor %s does not support the options %s", compressor, strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
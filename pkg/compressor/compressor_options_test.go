@@ -0,0 +1,123 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should configure compressors using options", func() {
+	_ = It("should parse option strings", func() {
+		options, e := ParseOptions(3, "store:.png|.jpg + Comment:assets")
+		Expect(e).To(BeNil())
+		Expect(options.Level).To(BeEquivalentTo(3))
+		Expect(options.Values).To(Equal(map[string]string{"store": ".png|.jpg", "comment": "assets"}))
+
+		options, e = ParseOptions(0, "")
+		Expect(e).To(BeNil())
+		Expect(options.IsZero()).To(BeTrue())
+
+		_, e = ParseOptions(0, "store")
+		Expect(e).ToNot(BeNil())
+	})
+
+	_ = It("should create configured instances from the registry", func() {
+		registry := NewMapRegistry().PutFactory("tar", NewTar)
+
+		fast, e := registry.Create("tar", Options{Level: 1})
+		Expect(e).To(BeNil())
+		Expect(fast.(*Tar).Level).To(BeEquivalentTo(1))
+
+		best, e := registry.Create("TAR", Options{Level: 9})
+		Expect(e).To(BeNil())
+		Expect(best).ToNot(BeIdenticalTo(fast))
+
+		Expect(registry.Find("tar").(*Tar).Level).To(BeEquivalentTo(9))
+	})
+
+	_ = It("should reject invalid options", func() {
+		_, e := DefaultRegistry.Create("tar", Options{Level: 10})
+		Expect(e).ToNot(BeNil())
+
+		_, e = DefaultRegistry.Create("tar", Options{Values: map[string]string{"store": ".png"}})
+		Expect(e).ToNot(BeNil())
+
+		_, e = DefaultRegistry.Create("store", Options{Level: 1})
+		Expect(e).ToNot(BeNil())
+
+		_, e = DefaultRegistry.Create("unknown", Options{})
+		Expect(e).ToNot(BeNil())
+	})
+
+	_ = It("should only return plain instances without options", func() {
+		registry := NewMapRegistry().Put("custom", &Store{})
+
+		compressor, e := registry.Create("custom", Options{})
+		Expect(e).To(BeNil())
+		Expect(compressor).To(BeIdenticalTo(registry.Find("custom")))
+
+		_, e = registry.Create("custom", Options{Level: 1})
+		Expect(e).ToNot(BeNil())
+	})
+
+	_ = It("should list the ids of instances and factories", func() {
+		registry := NewMapRegistry().Put("custom", &Store{}).PutFactory("tar", NewTar).PutFactory("indexed", NewIndexed)
+		Expect(registry.IDs()).To(Equal([]string{"custom", "indexed", "tar"}))
+	})
+
+	_ = It("should compress better with a higher level", func() {
+		directory := files.NewRootDirectory()
+		for i := 0; i < 20; i++ {
+			content := strings.Repeat(fmt.Sprintf("line %d with some repeated content\n", i), 500)
+			Expect(directory.NewFile(paths.Of(fmt.Sprintf("file-%d.txt", i))).Write(bytes.NewBufferString(content))).To(BeNil())
+		}
+
+		sizeOf := func(level int) int {
+			compressor, e := DefaultRegistry.Create("tar", Options{Level: level})
+			Expect(e).To(BeNil())
+
+			buffer := &bytes.Buffer{}
+			Expect(compressor.Compress(context.Background(), directory, buffer)).To(BeNil())
+			return buffer.Len()
+		}
+
+		Expect(sizeOf(1)).To(BeNumerically(">", sizeOf(9)))
+	})
+
+	_ = It("should store configured extensions in zip files", func() {
+		compressor, e := DefaultRegistry.Create("zip", Options{Values: map[string]string{"store": ".PNG|.jpg"}})
+		Expect(e).To(BeNil())
+
+		directory := files.NewRootDirectory()
+		image := directory.NewFile(paths.Of("image.png"))
+		Expect(compressor.(*Zip).Store(image)).To(BeTrue())
+		Expect(compressor.(*Zip).Store(directory.NewFile(paths.Of("text.txt")))).To(BeFalse())
+	})
+})
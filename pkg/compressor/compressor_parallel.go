@@ -0,0 +1,428 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"github.com/homeport/pina-golada/pkg/files"
+)
+
+const (
+	// DefaultBlockSize is the amount of uncompressed bytes of every member written by the ParallelGzip codec
+	DefaultBlockSize = 1024 * 1024
+
+	// The fixed parts of a gzip member as defined by RFC 1952
+	gzipID1, gzipID2, gzipDeflate = 0x1f, 0x8b, 8
+	gzipFlagExtra                 = 1 << 2
+	gzipHeaderSize                = 10
+	gzipTrailerSize               = 8
+
+	// parallelSubfield is the id of the extra subfield that stores the size of a member, followed by its length
+	parallelSubfield     = "PG"
+	parallelSubfieldSize = 4
+	parallelExtraSize    = 4 + parallelSubfieldSize
+)
+
+var (
+	// ErrInvalidParallelGzip is returned when the members of a stream written by the ParallelGzip codec are corrupt
+	ErrInvalidParallelGzip = errors.New("invalid parallel gzip stream")
+)
+
+// Parallel is an implementation of the compressor interface which writes tar archives using the ParallelGzip
+// codec. Its archives can also be decompressed by the Tar compressor.
+type Parallel struct {
+	Level     int
+	BlockSize int
+}
+
+// NewParallel creates a parallel compressor. The compression level and the block size in KiB can be configured,
+// the latter using the block option.
+func NewParallel(options Options) (Compressor, error) {
+	if err := options.checkKeys("parallel", "block"); err != nil {
+		return nil, err
+	}
+
+	level, err := options.flateLevel(flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	blockSize := DefaultBlockSize
+	if value, ok := options.Values["block"]; ok {
+		kilobytes, err := strconv.Atoi(value)
+		if err != nil || kilobytes < 1 || kilobytes > 1024*1024 {
+			return nil, fmt.Errorf("invalid block size %s, expected 1 to %d KiB", value, 1024*1024)
+		}
+		blockSize = kilobytes * 1024
+	}
+
+	return &Parallel{Level: level, BlockSize: blockSize}, nil
+}
+
+// Metadata describes the parallel compressor
+func (p *Parallel) Metadata() Metadata {
+	return Metadata{
+		ID:                        "parallel",
+		Description:               "tar archive compressed as gzip blocks on all cores",
+		Extension:                 ".tar.gz",
+		MediaType:                 "application/gzip",
+		PreservesPermissions:      true,
+		PreservesEmptyDirectories: true,
+	}
+}
+
+// Compress compresses the directory into the writer
+func (p *Parallel) Compress(ctx context.Context, directory files.Directory, writer io.Writer) error {
+	return p.pipeline().Compress(ctx, directory, writer)
+}
+
+// Decompress decompresses the reader into the directory
+func (p *Parallel) Decompress(ctx context.Context, reader io.Reader) (files.Directory, error) {
+	return p.pipeline().Decompress(ctx, reader)
+}
+
+// pipeline returns the pipeline that implements the parallel compressor
+func (p *Parallel) pipeline() *Pipeline {
+	return &Pipeline{Archiver: &TarArchiver{}, Codec: &ParallelGzip{Level: p.Level, BlockSize: p.BlockSize}}
+}
+
+// ParallelGzip is a codec that splits the stream into blocks, which are compressed on all cores and written as
+// members of a multi-member gzip stream. The output only depends on the level and the block size, not on the
+// amount of workers. Every member stores its size in an extra subfield, so members are decompressed in parallel
+// as well. Streams of other gzip writers are decompressed sequentially.
+//
+// Level is the compression level, flate.BestCompression is used if it is zero. BlockSize is the amount of
+// uncompressed bytes per member, DefaultBlockSize is used if it is zero. Workers limits the amount of blocks
+// processed at the same time, it defaults to GOMAXPROCS.
+type ParallelGzip struct {
+	Level     int
+	BlockSize int
+	Workers   int
+}
+
+// NewParallelGzip creates a parallel gzip codec. Only the compression level can be configured.
+func NewParallelGzip(options Options) (Codec, error) {
+	level, err := codecLevel("pgzip", options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ParallelGzip{Level: level}, nil
+}
+
+// Metadata describes the parallel gzip codec
+func (g *ParallelGzip) Metadata() Metadata {
+	return Metadata{ID: "pgzip", Description: "gzip members compressed on all cores", Extension: ".gz", MediaType: "application/gzip"}
+}
+
+// settings returns the level, block size and workers of the codec, replacing zero values by their defaults
+func (g *ParallelGzip) settings() (level int, blockSize int, workers int, e error) {
+	if level, e = (Options{Level: g.Level}).flateLevel(flate.BestCompression); e != nil {
+		return 0, 0, 0, e
+	}
+
+	blockSize, workers = g.BlockSize, g.Workers
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	return level, blockSize, workers, nil
+}
+
+// Writer returns a writer that compresses blocks in parallel
+func (g *ParallelGzip) Writer(writer io.Writer) (io.WriteCloser, error) {
+	level, blockSize, workers, err := g.settings()
+	if err != nil {
+		return nil, err
+	}
+
+	return &parallelGzipWriter{writer: writer, level: level, blockSize: blockSize, workers: workers}, nil
+}
+
+// Reader returns a reader of the decompressed stream. The members are decompressed in parallel once the
+// stream was read completely.
+func (g *ParallelGzip) Reader(reader io.Reader) (io.ReadCloser, error) {
+	_, _, workers, err := g.settings()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := decompressMembers(data, workers)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+// memberResult is a compressed member or the error compressing it
+type memberResult struct {
+	data []byte
+	err  error
+}
+
+// parallelGzipWriter buffers blocks and compresses up to workers of them at the same time. Members are written
+// in the order of their blocks.
+type parallelGzipWriter struct {
+	writer    io.Writer
+	level     int
+	blockSize int
+	workers   int
+
+	buffer  []byte
+	pending []chan memberResult
+	written bool
+	err     error
+}
+
+// Write buffers the bytes and starts compressing every complete block
+func (w *parallelGzipWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.buffer = append(w.buffer, p...)
+	for len(w.buffer) >= w.blockSize {
+		block := make([]byte, w.blockSize)
+		copy(block, w.buffer)
+		w.buffer = append(w.buffer[:0], w.buffer[w.blockSize:]...)
+
+		if err := w.submit(block); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// submit starts compressing the block, after writing the oldest member if all workers are busy
+func (w *parallelGzipWriter) submit(block []byte) error {
+	if len(w.pending) >= w.workers {
+		if err := w.writeOldest(); err != nil {
+			return err
+		}
+	}
+
+	result := make(chan memberResult, 1)
+	go func(level int) {
+		data, err := compressMember(block, level)
+		result <- memberResult{data: data, err: err}
+	}(w.level)
+
+	w.pending = append(w.pending, result)
+	w.written = true
+	return nil
+}
+
+// writeOldest waits for the oldest pending member and writes it. Once writing failed, the members are only
+// drained, as the stream would have a gap otherwise
+func (w *parallelGzipWriter) writeOldest() error {
+	result := <-w.pending[0]
+	w.pending = w.pending[1:]
+
+	if w.err != nil {
+		return w.err
+	}
+
+	if result.err != nil {
+		w.err = result.err
+		return w.err
+	}
+
+	if _, err := w.writer.Write(result.data); err != nil {
+		w.err = err
+		return w.err
+	}
+
+	return nil
+}
+
+// Close compresses the remaining bytes and writes all pending members. An empty stream consists of one empty
+// member, so it is a valid gzip stream.
+func (w *parallelGzipWriter) Close() error {
+	if w.err == nil && (len(w.buffer) > 0 || !w.written) {
+		_ = w.submit(w.buffer)
+		w.buffer = nil
+	}
+
+	for len(w.pending) > 0 { // Let the remaining workers finish, even if writing failed
+		_ = w.writeOldest()
+	}
+
+	return w.err
+}
+
+// compressMember compresses the block into a gzip member that stores its size in the parallelSubfield
+func compressMember(block []byte, level int) ([]byte, error) {
+	output := bytes.NewBuffer(make([]byte, gzipHeaderSize+2+parallelExtraSize, len(block)/2+64))
+
+	flateWriter, err := flate.NewWriter(output, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := flateWriter.Write(block); err != nil {
+		return nil, err
+	}
+
+	if err := flateWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	trailer := make([]byte, gzipTrailerSize)
+	binary.LittleEndian.PutUint32(trailer, crc32.ChecksumIEEE(block))
+	binary.LittleEndian.PutUint32(trailer[4:], uint32(len(block)))
+	output.Write(trailer)
+
+	member := output.Bytes()
+	copy(member, []byte{gzipID1, gzipID2, gzipDeflate, gzipFlagExtra, 0, 0, 0, 0, 0, gzipUnknownOS})
+	binary.LittleEndian.PutUint16(member[gzipHeaderSize:], parallelExtraSize)
+	copy(member[gzipHeaderSize+2:], parallelSubfield)
+	binary.LittleEndian.PutUint16(member[gzipHeaderSize+4:], parallelSubfieldSize)
+	binary.LittleEndian.PutUint32(member[gzipHeaderSize+6:], uint32(len(member)))
+
+	return member, nil
+}
+
+// memberSize returns the size that a member at the start of the data stores in its parallelSubfield, ok is false
+// if the member was not written by the ParallelGzip codec
+func memberSize(data []byte) (size int, ok bool) {
+	headerSize := gzipHeaderSize + 2 + parallelExtraSize
+	if len(data) < headerSize || data[0] != gzipID1 || data[1] != gzipID2 || data[2] != gzipDeflate ||
+		data[3] != gzipFlagExtra || binary.LittleEndian.Uint16(data[gzipHeaderSize:]) != parallelExtraSize ||
+		string(data[gzipHeaderSize+2:gzipHeaderSize+4]) != parallelSubfield ||
+		binary.LittleEndian.Uint16(data[gzipHeaderSize+4:]) != parallelSubfieldSize {
+		return 0, false
+	}
+
+	size = int(binary.LittleEndian.Uint32(data[gzipHeaderSize+6:]))
+	return size, size >= headerSize+gzipTrailerSize && size <= len(data)
+}
+
+// decompressMembers splits the data into members and decompresses up to workers of them at the same time into
+// their part of the content. The data following the last member written by the ParallelGzip codec is
+// decompressed as ordinary gzip stream.
+func decompressMembers(data []byte, workers int) ([]byte, error) {
+	var members [][]byte
+	var offsets []int
+	total := 0
+	for len(data) > 0 {
+		size, ok := memberSize(data)
+		if !ok {
+			break
+		}
+
+		member := data[:size]
+		declared := int(binary.LittleEndian.Uint32(member[size-gzipTrailerSize+4:]))
+		if uint64(declared) > uint64(size)*maxDeflateRatio {
+			return nil, fmt.Errorf("%w: member %d declares %d bytes", ErrInvalidParallelGzip, len(members), declared)
+		}
+
+		members, offsets = append(members, member), append(offsets, total)
+		total += declared
+		data = data[size:]
+	}
+
+	content := make([]byte, total)
+	errs := make([]error, len(members))
+	semaphore := make(chan struct{}, workers)
+	var group sync.WaitGroup
+	for index := range members {
+		end := total
+		if index+1 < len(offsets) {
+			end = offsets[index+1]
+		}
+
+		semaphore <- struct{}{}
+		group.Add(1)
+		go func(index int, target []byte) {
+			defer func() { <-semaphore; group.Done() }()
+			errs[index] = decompressMember(members[index], target)
+		}(index, content[offsets[index]:end])
+	}
+	group.Wait()
+
+	for index, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%w: member %d: %v", ErrInvalidParallelGzip, index, err)
+		}
+	}
+
+	if len(data) > 0 || len(members) == 0 {
+		rest, err := (&Gzip{}).Reader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer rest.Close()
+
+		remaining, err := ioutil.ReadAll(rest)
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, remaining...)
+	}
+
+	return content, nil
+}
+
+// decompressMember inflates a member into the target, which has the size stored in the member, and verifies
+// the checksum
+func decompressMember(member []byte, target []byte) error {
+	body := member[gzipHeaderSize+2+parallelExtraSize : len(member)-gzipTrailerSize]
+
+	flateReader := flate.NewReader(bytes.NewReader(body))
+	defer flateReader.Close()
+
+	if _, err := io.ReadFull(flateReader, target); err != nil {
+		return err
+	}
+
+	if n, _ := flateReader.Read(make([]byte, 1)); n > 0 {
+		return errors.New("content exceeds the declared size")
+	}
+
+	if crc32.ChecksumIEEE(target) != binary.LittleEndian.Uint32(member[len(member)-gzipTrailerSize:]) {
+		return errors.New("checksum mismatch")
+	}
+
+	return nil
+}
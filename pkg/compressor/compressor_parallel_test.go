@@ -0,0 +1,228 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+// largeDirectory creates a directory with the amount of files of pseudo random text of the given size
+func largeDirectory(count int, size int) files.Directory {
+	random := rand.New(rand.NewSource(42))
+	words := []string{"pina", "golada", "asset", "compressor", "block", "member", "gzip", "parallel"}
+
+	directory := files.NewRootDirectory()
+	for i := 0; i < count; i++ {
+		content := &bytes.Buffer{}
+		for content.Len() < size {
+			_, _ = fmt.Fprintf(content, "%s %d\n", words[random.Intn(len(words))], random.Intn(1000))
+		}
+		_ = directory.NewFile(paths.Of(fmt.Sprintf("data/%d/file-%d.txt", i%4, i))).Write(content)
+	}
+
+	return directory
+}
+
+// laterFailingWriter succeeds for the first write and fails every later one, counting all writes
+type laterFailingWriter struct {
+	writes int
+}
+
+// Write fails unless it is the first write
+func (w *laterFailingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > 1 {
+		return 0, errFailingWriter
+	}
+	return len(p), nil
+}
+
+var _ = Describe("should compress blocks in parallel", func() {
+	var directory files.Directory
+
+	_ = BeforeEach(func() {
+		directory = largeDirectory(8, 64*1024)
+	})
+
+	_ = It("should round trip with many members", func() {
+		compressor := &Parallel{BlockSize: 16 * 1024}
+
+		buffer := &bytes.Buffer{}
+		Expect(compressor.Compress(context.Background(), directory, buffer)).To(BeNil())
+
+		result, e := compressor.Decompress(context.Background(), buffer)
+		Expect(e).To(BeNil())
+
+		expected, e := TreeChecksum(directory)
+		Expect(e).To(BeNil())
+		Expect(TreeChecksum(result)).To(Equal(expected))
+	})
+
+	_ = It("should write the same output regardless of the amount of workers", func() {
+		compress := func(workers int) []byte {
+			buffer := &bytes.Buffer{}
+			writer, e := (&ParallelGzip{BlockSize: 10000, Workers: workers}).Writer(buffer)
+			Expect(e).To(BeNil())
+			Expect((&TarArchiver{}).Compress(context.Background(), directory, writer)).To(BeNil())
+			Expect(writer.Close()).To(BeNil())
+			return buffer.Bytes()
+		}
+
+		single := compress(1)
+		Expect(compress(3)).To(Equal(single))
+		Expect(compress(16)).To(Equal(single))
+
+		previous := runtime.GOMAXPROCS(1)
+		defer runtime.GOMAXPROCS(previous)
+		Expect(compress(0)).To(Equal(single))
+	})
+
+	_ = It("should write multi-member gzip streams that the tar compressor reads", func() {
+		buffer := &bytes.Buffer{}
+		Expect((&Parallel{BlockSize: 4096}).Compress(context.Background(), directory, buffer)).To(BeNil())
+
+		gzipReader, e := gzip.NewReader(bytes.NewReader(buffer.Bytes()))
+		Expect(e).To(BeNil())
+		Expect(gzipReader.Header.OS).To(BeEquivalentTo(gzipUnknownOS))
+		Expect(ioutil.ReadAll(gzipReader)).ToNot(BeEmpty())
+
+		result, e := (&Tar{}).Decompress(context.Background(), buffer)
+		Expect(e).To(BeNil())
+
+		expected, e := TreeChecksum(directory)
+		Expect(e).To(BeNil())
+		Expect(TreeChecksum(result)).To(Equal(expected))
+	})
+
+	_ = It("should read ordinary gzip streams", func() {
+		buffer := &bytes.Buffer{}
+		Expect((&Tar{}).Compress(context.Background(), directory, buffer)).To(BeNil())
+
+		result, e := (&Parallel{}).Decompress(context.Background(), buffer)
+		Expect(e).To(BeNil())
+
+		expected, e := TreeChecksum(directory)
+		Expect(e).To(BeNil())
+		Expect(TreeChecksum(result)).To(Equal(expected))
+	})
+
+	_ = It("should write a valid stream without content", func() {
+		buffer := &bytes.Buffer{}
+		writer, e := (&ParallelGzip{}).Writer(buffer)
+		Expect(e).To(BeNil())
+		Expect(writer.Close()).To(BeNil())
+
+		gzipReader, e := gzip.NewReader(bytes.NewReader(buffer.Bytes()))
+		Expect(e).To(BeNil())
+		Expect(ioutil.ReadAll(gzipReader)).To(BeEmpty())
+
+		reader, e := (&ParallelGzip{}).Reader(buffer)
+		Expect(e).To(BeNil())
+		Expect(ioutil.ReadAll(reader)).To(BeEmpty())
+	})
+
+	_ = It("should detect corrupt members", func() {
+		buffer := &bytes.Buffer{}
+		writer, e := (&ParallelGzip{BlockSize: 1024}).Writer(buffer)
+		Expect(e).To(BeNil())
+		_, e = writer.Write(bytes.Repeat([]byte("corrupt "), 1024))
+		Expect(e).To(BeNil())
+		Expect(writer.Close()).To(BeNil())
+
+		data := buffer.Bytes()
+		size, ok := memberSize(data)
+		Expect(ok).To(BeTrue())
+		data[size-gzipTrailerSize] ^= 0xff
+
+		_, e = (&ParallelGzip{}).Reader(bytes.NewReader(data))
+		Expect(errors.Is(e, ErrInvalidParallelGzip)).To(BeTrue())
+	})
+
+	_ = It("should stop writing members once a member could not be written", func() {
+		output := &laterFailingWriter{}
+		writer := &parallelGzipWriter{writer: output, level: 1, blockSize: 1024, workers: 2}
+
+		_, e := writer.Write(bytes.Repeat([]byte("member"), 1024))
+		Expect(errors.Is(e, errFailingWriter)).To(BeTrue())
+		Expect(errors.Is(writer.Close(), errFailingWriter)).To(BeTrue())
+		Expect(output.writes).To(BeEquivalentTo(2))
+		Expect(writer.pending).To(BeEmpty())
+	})
+
+	_ = It("should configure the block size", func() {
+		compressor, e := DefaultRegistry.Create("parallel", Options{Level: 1, Values: map[string]string{"block": "64"}})
+		Expect(e).To(BeNil())
+		Expect(compressor.(*Parallel).Level).To(BeEquivalentTo(1))
+		Expect(compressor.(*Parallel).BlockSize).To(BeEquivalentTo(64 * 1024))
+
+		_, e = DefaultRegistry.Create("parallel", Options{Values: map[string]string{"block": "0"}})
+		Expect(e).ToNot(BeNil())
+
+		Expect(DefaultRegistry.Find("tar+pgzip")).ToNot(BeNil())
+	})
+})
+
+func BenchmarkParallel(b *testing.B) {
+	directory := largeDirectory(32, 128*1024)
+	compressors := []struct {
+		name       string
+		compressor Compressor
+	}{
+		{"tar", &Tar{}},
+		{"parallel", &Parallel{}},
+	}
+
+	for _, entry := range compressors {
+		buffer := &bytes.Buffer{}
+		b.Run("compress/"+entry.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				buffer.Reset()
+				if err := entry.compressor.Compress(context.Background(), directory, buffer); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(buffer.Len()), "size")
+		})
+
+		b.Run("decompress/"+entry.name, func(b *testing.B) {
+			b.SetBytes(int64(buffer.Len()))
+			for i := 0; i < b.N; i++ {
+				if _, err := entry.compressor.Decompress(context.Background(), bytes.NewReader(buffer.Bytes())); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
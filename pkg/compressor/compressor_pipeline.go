@@ -0,0 +1,137 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/homeport/pina-golada/pkg/files"
+)
+
+// pipelineSeparator joins the id of the archiver and the id of the codec of a pipeline, e.g. tar+zlib
+const pipelineSeparator = "+"
+
+// Pipeline is a compressor that writes the archive of the archiver through the codec
+type Pipeline struct {
+	Archiver Compressor
+	Codec    Codec
+}
+
+// Metadata combines the metadata of the archiver and the codec, e.g. to the extension .tar.zz of tar+zlib
+func (p *Pipeline) Metadata() Metadata {
+	archiver, codec := MetadataOf(p.Archiver), MetadataOf(p.Codec)
+
+	metadata := archiver
+	metadata.ID, metadata.Aliases = "", nil
+	if archiver.ID != "" && codec.ID != "" {
+		metadata.ID = archiver.ID + pipelineSeparator + codec.ID
+	}
+
+	metadata.Extension = archiver.Extension + codec.Extension
+	if codec.MediaType != "" {
+		metadata.MediaType = codec.MediaType
+	}
+
+	if codec.Description != "" {
+		metadata.Description = fmt.Sprintf("%s, encoded as %s", archiver.Description, codec.Description)
+	}
+
+	return metadata
+}
+
+// Compress writes the archive of the directory through the codec into the writer
+func (p *Pipeline) Compress(ctx context.Context, directory files.Directory, writer io.Writer) error {
+	encoder, err := p.Codec.Writer(writer)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Archiver.Compress(ctx, directory, encoder); err != nil {
+		_ = encoder.Close()
+		return err
+	}
+
+	return encoder.Close() // The codec has to be closed to flush the end of the stream
+}
+
+// Decompress decodes the reader using the codec and reads the archive off of it
+func (p *Pipeline) Decompress(ctx context.Context, reader io.Reader) (files.Directory, error) {
+	decoder, err := p.Codec.Reader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	directory, err := p.Archiver.Decompress(ctx, decoder)
+	if err == nil { // Read the rest of the stream, archivers stop at their end marker before the checksum of the codec
+		_, err = io.Copy(ioutil.Discard, decoder)
+	}
+
+	if err != nil {
+		_ = decoder.Close()
+		return nil, err
+	}
+
+	if err := decoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return directory, nil
+}
+
+// splitPipeline returns the archiver and codec ids of a pipeline id, ok is false if the id is no pipeline
+func splitPipeline(id string) (archiver string, codec string, ok bool) {
+	parts := strings.SplitN(id, pipelineSeparator, 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), true
+}
+
+// createPipeline creates the pipeline of the archiver and codec. The level of the options configures the codec,
+// the values configure the archiver.
+func (r *MapRegistry) createPipeline(archiverID string, codecID string, options Options) (Compressor, error) {
+	archiverFactory, ok := r.archivers[strings.ToLower(archiverID)]
+	if !ok {
+		return nil, fmt.Errorf("could not find archiver for %s", archiverID)
+	}
+
+	codecFactory, ok := r.codecs[strings.ToLower(codecID)]
+	if !ok {
+		return nil, fmt.Errorf("could not find codec for %s", codecID)
+	}
+
+	archiver, err := archiverFactory(Options{Values: options.Values})
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := codecFactory(Options{Level: options.Level})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pipeline{Archiver: archiver, Codec: codec}, nil
+}
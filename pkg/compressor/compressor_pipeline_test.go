@@ -0,0 +1,115 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should compose archivers and codecs", func() {
+	var directory files.Directory
+
+	compress := func(compressor Compressor) []byte {
+		buffer := &bytes.Buffer{}
+		Expect(compressor.Compress(context.Background(), directory, buffer)).To(BeNil())
+		return buffer.Bytes()
+	}
+
+	_ = BeforeEach(func() {
+		directory = files.NewRootDirectory()
+		content := bytes.Repeat([]byte("pina-golada "), 512)
+		Expect(directory.NewFile(paths.Of("usr/homeport/test.txt")).Write(bytes.NewReader(content))).To(BeNil())
+	})
+
+	for _, id := range []string{"tar+gzip", "tar+zlib", "tar+flate", "tar+identity", "zip+none", "store+zlib"} {
+		id := id
+
+		_ = It("should round trip "+id, func() {
+			compressor := DefaultRegistry.Find(id)
+			Expect(compressor).ToNot(BeNil())
+
+			result, e := compressor.Decompress(context.Background(), bytes.NewReader(compress(compressor)))
+			Expect(e).To(BeNil())
+
+			content := &bytes.Buffer{}
+			Expect(result.File(paths.Of("usr/homeport/test.txt")).CopyContent(content)).To(BeNil())
+			Expect(content.Bytes()).To(Equal(bytes.Repeat([]byte("pina-golada "), 512)))
+		})
+	}
+
+	_ = It("should keep tar as an alias of tar+gzip", func() {
+		Expect(compress(DefaultRegistry.Find("tar"))).To(Equal(compress(DefaultRegistry.Find("tar+gzip"))))
+
+		tar, e := DefaultRegistry.Create("tar", Options{Level: 1})
+		Expect(e).To(BeNil())
+		pipeline, e := DefaultRegistry.Create("tar+gzip", Options{Level: 1})
+		Expect(e).To(BeNil())
+		Expect(compress(tar)).To(Equal(compress(pipeline)))
+	})
+
+	_ = It("should configure the codec with the level and the archiver with the values", func() {
+		fast, e := DefaultRegistry.Create("tar+zlib", Options{Level: 1})
+		Expect(e).To(BeNil())
+		Expect(fast.(*Pipeline).Codec).To(Equal(&Zlib{Level: 1}))
+
+		zip, e := DefaultRegistry.Create("zip+identity", Options{Values: map[string]string{"store": ".txt"}})
+		Expect(e).To(BeNil())
+		Expect(zip.(*Pipeline).Archiver.(*Zip).Store).ToNot(BeNil())
+
+		_, e = DefaultRegistry.Create("tar+none", Options{Level: 1})
+		Expect(e).ToNot(BeNil())
+
+		_, e = DefaultRegistry.Create("tar+zlib", Options{Values: map[string]string{"store": ".txt"}})
+		Expect(e).ToNot(BeNil())
+	})
+
+	_ = It("should verify the trailer of the codec after the end of the archive", func() {
+		for _, id := range []string{"tar+gzip", "tar+zlib"} {
+			compressor := DefaultRegistry.Find(id)
+			compressed := compress(compressor)
+
+			_, e := compressor.Decompress(context.Background(), bytes.NewReader(compressed[:len(compressed)-2]))
+			Expect(e).To(HaveOccurred(), "truncated %s", id)
+
+			corrupt := append([]byte{}, compressed...)
+			corrupt[len(corrupt)-1] ^= 0xff
+			_, e = compressor.Decompress(context.Background(), bytes.NewReader(corrupt))
+			Expect(e).To(HaveOccurred(), "corrupt %s", id)
+		}
+	})
+
+	_ = It("should reject unknown archivers and codecs", func() {
+		_, e := DefaultRegistry.Create("rar+gzip", Options{})
+		Expect(e).ToNot(BeNil())
+
+		_, e = DefaultRegistry.Create("tar+xz", Options{})
+		Expect(e).ToNot(BeNil())
+
+		Expect(DefaultRegistry.Find("tar+xz")).To(BeNil())
+	})
+})
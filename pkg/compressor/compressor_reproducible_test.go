@@ -0,0 +1,130 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should create reproducible archives", func() {
+	var names = []string{"b/2.txt", "a/1.txt", "c.txt", "a/nested/3.txt", "a.txt"}
+
+	// treeInOrder creates a directory containing the named files, created in the order of the indices
+	treeInOrder := func(indices ...int) files.Directory {
+		directory := files.NewRootDirectory()
+		for _, index := range indices {
+			Expect(directory.NewFile(paths.Of(names[index])).WithPermission(0644).
+				Write(bytes.NewBufferString(names[index]))).To(BeNil())
+		}
+		return directory
+	}
+
+	// diskInOrder creates the named files on disk in the order of the indices, with distinct modification times,
+	// and loads them into a directory
+	diskInOrder := func(indices ...int) files.Directory {
+		tempDir, e := ioutil.TempDir("", "pgl-reproducible")
+		Expect(e).To(BeNil())
+		defer os.RemoveAll(tempDir)
+
+		for position, index := range indices {
+			path := filepath.Join(tempDir, filepath.FromSlash(names[index]))
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(BeNil())
+			Expect(ioutil.WriteFile(path, []byte(names[index]), 0644)).To(BeNil())
+
+			modified := time.Now().Add(time.Duration(position) * time.Hour)
+			Expect(os.Chtimes(path, modified, modified)).To(BeNil())
+		}
+
+		directory := files.NewRootDirectory()
+		Expect(os.Chmod(tempDir, 0755)).To(BeNil())
+		Expect(files.LoadFromDisk(directory, tempDir)).To(BeNil())
+		return directory
+	}
+
+	compress := func(compressor Compressor, directory files.Directory) []byte {
+		buffer := &bytes.Buffer{}
+		Expect(compressor.Compress(context.Background(), directory, buffer)).To(BeNil())
+		return buffer.Bytes()
+	}
+
+	for name, compressor := range map[string]Compressor{"tar": &Tar{}, "zip": &Zip{}, "store": &Store{}, "indexed": &Indexed{}} {
+		name, compressor := name, compressor
+
+		_ = It("should create identical "+name+" archives for trees built in different orders", func() {
+			first := compress(compressor, treeInOrder(0, 1, 2, 3, 4))
+			Expect(compress(compressor, treeInOrder(4, 3, 2, 1, 0))).To(Equal(first))
+			Expect(compress(compressor, treeInOrder(2, 0, 4, 1, 3))).To(Equal(first))
+		})
+
+		_ = It("should create identical "+name+" archives for trees loaded from disk", func() {
+			first := compress(compressor, diskInOrder(0, 1, 2, 3, 4))
+			time.Sleep(10 * time.Millisecond)
+			Expect(compress(compressor, diskInOrder(4, 3, 2, 1, 0))).To(Equal(first))
+		})
+	}
+
+	_ = It("should write sorted tar entries with directories before their files", func() {
+		gzipReader, e := gzip.NewReader(bytes.NewReader(compress(&Tar{}, treeInOrder(4, 3, 2, 1, 0))))
+		Expect(e).To(BeNil())
+		Expect(gzipReader.Header.ModTime.Unix()).To(BeNumerically("<=", 0))
+		Expect(gzipReader.Header.OS).To(BeEquivalentTo(gzipUnknownOS))
+
+		var entries []string
+		tarReader := tar.NewReader(gzipReader)
+		for {
+			header, e := tarReader.Next()
+			if e == io.EOF {
+				break
+			}
+			Expect(e).To(BeNil())
+			Expect(header.ModTime.Unix()).To(BeEquivalentTo(0))
+			Expect(header.Uid).To(BeEquivalentTo(0))
+			Expect(header.Uname).To(BeEmpty())
+
+			entries = append(entries, header.Name)
+		}
+
+		Expect(entries).To(Equal([]string{
+			"/a",
+			"/a/1.txt",
+			"/a/nested",
+			"/a/nested/3.txt",
+			"/a.txt",
+			"/b",
+			"/b/2.txt",
+			"/c.txt",
+		}))
+	})
+})
@@ -0,0 +1,152 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/homeport/pina-golada/pkg/files"
+)
+
+var (
+	// TrustedKeys are the public keys that are trusted by the Verified compressors of generated code in addition
+	// to the key that was used to sign the assets when they were generated
+	TrustedKeys []ed25519.PublicKey
+
+	// ErrInvalidSignature is matched by every SignatureError
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrInvalidSigningKey is returned for keys that are not hex encoded ed25519 keys
+	ErrInvalidSigningKey = errors.New("invalid signing key, expected a hex encoded ed25519 seed or private key")
+)
+
+// SignatureError is returned when a blob does not match its signature for any of the trusted keys
+type SignatureError struct {
+	Reason string
+}
+
+// Error returns the reason of the error
+func (e *SignatureError) Error() string {
+	return "invalid signature: " + e.Reason
+}
+
+// Is matches ErrInvalidSignature
+func (e *SignatureError) Is(target error) bool {
+	return target == ErrInvalidSignature
+}
+
+// DecodeSigningKey decodes a hex encoded ed25519 seed or private key
+func DecodeSigningKey(encoded string) (ed25519.PrivateKey, error) {
+	key, e := hex.DecodeString(strings.TrimSpace(encoded))
+	if e != nil {
+		return nil, ErrInvalidSigningKey
+	}
+
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+
+	default:
+		return nil, ErrInvalidSigningKey
+	}
+}
+
+// DecodePublicKey decodes a hex encoded ed25519 public key
+func DecodePublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, e := hex.DecodeString(strings.TrimSpace(encoded))
+	if e != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key, expected %d hex encoded bytes", ed25519.PublicKeySize)
+	}
+
+	return ed25519.PublicKey(key), nil
+}
+
+// Sign signs the blob with the private key
+func Sign(key ed25519.PrivateKey, blob []byte) []byte {
+	return ed25519.Sign(key, blob)
+}
+
+// Verify returns a SignatureError unless the signature of the blob was created by one of the trusted keys
+func Verify(blob []byte, signature []byte, trusted ...ed25519.PublicKey) error {
+	if len(trusted) == 0 {
+		return &SignatureError{Reason: "no trusted keys"}
+	}
+
+	if len(signature) != ed25519.SignatureSize {
+		return &SignatureError{Reason: fmt.Sprintf("expected %d bytes but got %d", ed25519.SignatureSize, len(signature))}
+	}
+
+	for _, key := range trusted {
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, blob, signature) {
+			return nil
+		}
+	}
+
+	return &SignatureError{Reason: "the data was modified or signed by an untrusted key"}
+}
+
+// Verified is a compressor that verifies the signature of the blob against the trusted keys before the inner
+// compressor decompresses it. Compress does not sign, the builder stores the signature next to the blob.
+type Verified struct {
+	Inner     Compressor
+	Signature []byte
+	Trusted   []ed25519.PublicKey
+}
+
+// NewVerified creates a compressor that only decompresses blobs with a valid signature of a trusted key
+func NewVerified(inner Compressor, signature []byte, trusted ...ed25519.PublicKey) *Verified {
+	return &Verified{Inner: inner, Signature: signature, Trusted: trusted}
+}
+
+// Metadata describes the inner compressor, as the signature is not part of its output
+func (v *Verified) Metadata() Metadata {
+	return MetadataOf(v.Inner)
+}
+
+// Compress compresses the directory using the inner compressor
+func (v *Verified) Compress(ctx context.Context, directory files.Directory, writer io.Writer) error {
+	return v.Inner.Compress(ctx, directory, writer)
+}
+
+// Decompress verifies the blob of the reader and decompresses it using the inner compressor
+func (v *Verified) Decompress(ctx context.Context, reader io.Reader) (files.Directory, error) {
+	blob, e := ioutil.ReadAll(reader)
+	if e != nil {
+		return nil, e
+	}
+
+	if err := Verify(blob, v.Signature, v.Trusted...); err != nil {
+		return nil, err
+	}
+
+	return v.Inner.Decompress(ctx, bytes.NewReader(blob))
+}
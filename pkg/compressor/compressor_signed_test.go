@@ -0,0 +1,101 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should verify signed assets", func() {
+	var (
+		blob    []byte
+		key     ed25519.PrivateKey
+		trusted ed25519.PublicKey
+	)
+
+	_ = BeforeEach(func() {
+		directory := files.NewRootDirectory()
+		Expect(directory.NewFile(paths.Of("templates/license.txt")).Write(bytes.NewBufferString("license"))).To(BeNil())
+
+		buffer := &bytes.Buffer{}
+		Expect((&Store{}).Compress(context.Background(), directory, buffer)).To(BeNil())
+		blob = buffer.Bytes()
+
+		key = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+		trusted = key.Public().(ed25519.PublicKey)
+	})
+
+	_ = It("should decompress blobs signed by a trusted key", func() {
+		other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+
+		result, e := NewVerified(&Store{}, Sign(key, blob), other, trusted).Decompress(context.Background(), bytes.NewReader(blob))
+		Expect(e).To(BeNil())
+		Expect(result.File(paths.Of("templates/license.txt"))).ToNot(BeNil())
+	})
+
+	_ = It("should fail with a typed error for tampered blobs", func() {
+		signature := Sign(key, blob)
+		blob[len(blob)-2] ^= 1
+
+		_, e := NewVerified(&Store{}, signature, trusted).Decompress(context.Background(), bytes.NewReader(blob))
+		Expect(errors.Is(e, ErrInvalidSignature)).To(BeTrue())
+
+		var signatureError *SignatureError
+		Expect(errors.As(e, &signatureError)).To(BeTrue())
+	})
+
+	_ = It("should fail for untrusted keys and malformed signatures", func() {
+		untrusted := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{3}, ed25519.SeedSize))
+		Expect(errors.Is(Verify(blob, Sign(untrusted, blob), trusted), ErrInvalidSignature)).To(BeTrue())
+		Expect(errors.Is(Verify(blob, Sign(key, blob)), ErrInvalidSignature)).To(BeTrue())
+		Expect(errors.Is(Verify(blob, []byte("short"), trusted), ErrInvalidSignature)).To(BeTrue())
+	})
+
+	_ = It("should decode seeds, private and public keys", func() {
+		fromSeed, e := DecodeSigningKey("0101010101010101010101010101010101010101010101010101010101010101")
+		Expect(e).To(BeNil())
+		Expect(fromSeed).To(Equal(key))
+
+		fromKey, e := DecodeSigningKey(hex.EncodeToString(key))
+		Expect(e).To(BeNil())
+		Expect(fromKey).To(Equal(key))
+
+		public, e := DecodePublicKey(hex.EncodeToString(trusted))
+		Expect(e).To(BeNil())
+		Expect(public).To(Equal(trusted))
+
+		_, e = DecodeSigningKey("0101")
+		Expect(errors.Is(e, ErrInvalidSigningKey)).To(BeTrue())
+
+		_, e = DecodePublicKey("0101")
+		Expect(e).ToNot(BeNil())
+	})
+})
@@ -0,0 +1,235 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+const (
+	// storeMagic starts every archive written by the Store compressor, followed by the format version
+	storeMagic   = "PGLS"
+	storeVersion = 1
+
+	// The entry types of the Store format, storeEnd marks the end of the archive
+	storeEnd       byte = 0
+	storeDirectory byte = 'd'
+	storeFile      byte = 'f'
+)
+
+var (
+	// ErrInvalidStoreArchive is returned when the input of Store.Decompress is not a valid archive
+	ErrInvalidStoreArchive = errors.New("invalid store archive")
+)
+
+// Store is an implementation of the compressor interface which serializes the directory tree without any
+// compression, so decompressing it costs next to nothing. It fits binaries that are compressed as a whole.
+//
+// The archive starts with "PGLS", a version byte and the uvarint permission set of the root. Every directory
+// and file follows as an entry type byte, the uvarint length prefixed path, the uvarint permission set and, for
+// files, the uvarint length prefixed content. Directories come before their content and a zero byte ends the
+// archive.
+type Store struct{}
+
+// NewStore creates a store compressor, which cannot be configured
+func NewStore(options Options) (Compressor, error) {
+	if options.Level != 0 {
+		return nil, fmt.Errorf("compressor store does not support a compression level")
+	}
+
+	if err := options.checkKeys("store"); err != nil {
+		return nil, err
+	}
+
+	return &Store{}, nil
+}
+
+// Metadata describes the store compressor
+func (s *Store) Metadata() Metadata {
+	return Metadata{
+		ID:                        "store",
+		Description:               "uncompressed serialization of the tree",
+		Extension:                 ".pgls",
+		MediaType:                 mediaTypeBinary,
+		PreservesPermissions:      true,
+		PreservesEmptyDirectories: true,
+	}
+}
+
+// Compress compresses the directory into the writer
+func (s *Store) Compress(ctx context.Context, directory files.Directory, writer io.Writer) error {
+	output := bufio.NewWriter(writer)
+
+	if _, err := output.WriteString(storeMagic); err != nil {
+		return err
+	}
+
+	if err := output.WriteByte(storeVersion); err != nil {
+		return err
+	}
+
+	if err := writeUvarint(output, uint64(directory.PermissionSet())); err != nil {
+		return err
+	}
+
+	if err := walkTree(ctx, directory, func(d files.Directory) error {
+		return writeStoreHeader(output, storeDirectory, d.AbsolutePath(), d.PermissionSet())
+	}, func(file files.File) error {
+		if err := writeStoreHeader(output, storeFile, file.AbsolutePath(), file.PermissionSet()); err != nil {
+			return err
+		}
+
+		content := &bytes.Buffer{}
+		if err := file.CopyContent(content); err != nil {
+			return err
+		}
+
+		if err := writeUvarint(output, uint64(content.Len())); err != nil {
+			return err
+		}
+
+		_, err := output.Write(content.Bytes())
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if err := output.WriteByte(storeEnd); err != nil {
+		return err
+	}
+
+	return output.Flush()
+}
+
+// writeStoreHeader writes the entry type, path and permission set of an entry
+func writeStoreHeader(writer io.Writer, entryType byte, path paths.Path, mode os.FileMode) error {
+	name := path.String()
+
+	if _, err := writer.Write([]byte{entryType}); err != nil {
+		return err
+	}
+
+	if err := writeUvarint(writer, uint64(len(name))); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(writer, name); err != nil {
+		return err
+	}
+
+	return writeUvarint(writer, uint64(mode))
+}
+
+// writeUvarint writes the value as unsigned varint
+func writeUvarint(writer io.Writer, value uint64) error {
+	buffer := make([]byte, binary.MaxVarintLen64)
+	_, err := writer.Write(buffer[:binary.PutUvarint(buffer, value)])
+	return err
+}
+
+// Decompress decompresses the reader into the directory
+func (s *Store) Decompress(ctx context.Context, reader io.Reader) (directory files.Directory, e error) {
+	data, e := ioutil.ReadAll(reader)
+	if e != nil {
+		return nil, e
+	}
+
+	input := &archiveReader{data: data, invalid: ErrInvalidStoreArchive}
+	header, e := input.next(len(storeMagic) + 1)
+	if e != nil {
+		return nil, e
+	}
+
+	if string(header[:len(storeMagic)]) != storeMagic {
+		return nil, fmt.Errorf("%w: missing magic bytes", ErrInvalidStoreArchive)
+	}
+
+	if header[len(storeMagic)] != storeVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidStoreArchive, header[len(storeMagic)])
+	}
+
+	rootMode, e := input.uvarint()
+	if e != nil {
+		return nil, e
+	}
+
+	root := files.NewRootDirectory()
+	root.WithPermission(os.FileMode(rootMode))
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		entryType, err := input.next(1)
+		if err != nil {
+			return nil, err
+		}
+
+		if entryType[0] == storeEnd {
+			return root, nil
+		}
+
+		if err := readStoreEntry(input, root, entryType[0]); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readStoreEntry reads the entry of the type into the root directory
+func readStoreEntry(r *archiveReader, root files.Directory, entryType byte) error {
+	path, e := r.path()
+	if e != nil {
+		return e
+	}
+
+	mode, e := r.uvarint()
+	if e != nil {
+		return e
+	}
+
+	switch entryType {
+	case storeDirectory:
+		root.NewDirectory(path).WithPermission(os.FileMode(mode))
+		return nil
+
+	case storeFile:
+		content, e := r.prefixed()
+		if e != nil {
+			return e
+		}
+
+		return root.NewFile(path).WithPermission(os.FileMode(mode)).Write(bytes.NewReader(content))
+	}
+
+	return fmt.Errorf("%w: unknown entry type %d", ErrInvalidStoreArchive, entryType)
+}
@@ -0,0 +1,207 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should store files correctly", func() {
+	var (
+		directory files.Directory
+		buffer    *bytes.Buffer
+	)
+
+	_ = BeforeEach(func() {
+		directory = files.NewRootDirectory()
+		buffer = &bytes.Buffer{}
+	})
+
+	_ = It("should be registered in the default registry", func() {
+		Expect(DefaultRegistry.Find("store")).ToNot(BeNil())
+		Expect(DefaultRegistry.Find("none")).ToNot(BeNil())
+	})
+
+	_ = It("should store and restore files, permissions and empty directories", func() {
+		Expect(files.LoadFromDisk(directory, "../../assets/tests/issue-35")).To(BeNil())
+		directory.NewDirectory(paths.Of("empty")).WithPermission(os.ModeDir | 0700)
+
+		Expect((&Store{}).Compress(context.Background(), directory, buffer)).To(BeNil())
+
+		result, e := (&Store{}).Decompress(context.Background(), buffer)
+		Expect(e).To(BeNil())
+
+		actual := &bytes.Buffer{}
+		Expect(files.WriteTree(actual, result, files.TreeOptions{ShowPermission: true, ShowChecksum: true, Sorted: true})).To(BeNil())
+
+		expected := &bytes.Buffer{}
+		Expect(files.WriteTree(expected, directory, files.TreeOptions{ShowPermission: true, ShowChecksum: true, Sorted: true})).To(BeNil())
+
+		Expect(actual.String()).To(BeEquivalentTo(expected.String()))
+	})
+
+	_ = It("should not compress the content", func() {
+		content := strings.Repeat("a", 4096)
+		Expect(directory.NewFile(paths.Of("file.txt")).Write(bytes.NewBufferString(content))).To(BeNil())
+
+		Expect((&Store{}).Compress(context.Background(), directory, buffer)).To(BeNil())
+		Expect(buffer.Len()).To(BeNumerically(">", len(content)))
+		Expect(buffer.String()).To(ContainSubstring(content))
+	})
+
+	_ = It("should reject invalid archives", func() {
+		_, e := (&Store{}).Decompress(context.Background(), bytes.NewBufferString("PK\x03\x04"))
+		Expect(errors.Is(e, ErrInvalidStoreArchive)).To(BeTrue())
+
+		Expect(directory.NewFile(paths.Of("file.txt")).Write(bytes.NewBufferString("content"))).To(BeNil())
+		Expect((&Store{}).Compress(context.Background(), directory, buffer)).To(BeNil())
+
+		_, e = (&Store{}).Decompress(context.Background(), bytes.NewReader(buffer.Bytes()[:buffer.Len()-3]))
+		Expect(errors.Is(e, ErrInvalidStoreArchive)).To(BeTrue())
+	})
+
+	_ = It("should reject malformed entry names", func() {
+		formats := []struct {
+			compressor Compressor
+			archive    func(entryType byte, name string) []byte
+			invalid    error
+		}{
+			{&Store{}, storeArchive, ErrInvalidStoreArchive},
+			{&Indexed{}, indexedArchive, ErrInvalidIndexedArchive},
+		}
+
+		for _, format := range formats {
+			for _, name := range []string{"", "/", "./", "../evil", "../evil/"} {
+				for _, entryType := range []byte{storeDirectory, storeFile} {
+					data := format.archive(entryType, name)
+
+					_, e := format.compressor.Decompress(context.Background(), bytes.NewReader(data))
+					Expect(errors.Is(e, format.invalid)).To(BeTrue(), "entry %q: %v", name, e)
+					if strings.HasPrefix(name, "..") {
+						Expect(errors.Is(e, paths.ErrOutsideRoot)).To(BeTrue(), "entry %q: %v", name, e)
+					}
+				}
+			}
+		}
+	})
+})
+
+// storeArchive returns a store archive with a single entry of the type and name
+func storeArchive(entryType byte, name string) []byte {
+	archive := &bytes.Buffer{}
+	archive.WriteString(storeMagic)
+	archive.WriteByte(storeVersion)
+	writeSingleEntry(archive, entryType, name)
+	return archive.Bytes()
+}
+
+// indexedArchive returns an indexed archive with a single entry of the type and name in its index
+func indexedArchive(entryType byte, name string) []byte {
+	archive := &bytes.Buffer{}
+	archive.WriteString(indexedMagic)
+	archive.WriteByte(indexedVersion)
+	writeSingleEntry(archive, entryType, name)
+
+	footer := make([]byte, indexedFooterSize)
+	binary.LittleEndian.PutUint64(footer, uint64(len(indexedMagic)+1))
+	copy(footer[8:], indexedMagic)
+	archive.Write(footer)
+	return archive.Bytes()
+}
+
+// writeSingleEntry writes the mode of the root, an entry of the type and name and the end of the entries
+func writeSingleEntry(archive *bytes.Buffer, entryType byte, name string) {
+	writeUvarint(archive, uint64(os.ModeDir|0755))
+	archive.WriteByte(entryType)
+	writeUvarint(archive, uint64(len(name)))
+	archive.WriteString(name)
+	writeUvarint(archive, 0644)
+	if entryType == storeFile {
+		writeUvarint(archive, 0)
+	}
+	archive.WriteByte(storeEnd)
+}
+
+// benchmarkDirectory creates a directory with text files of the given amount and size
+func benchmarkDirectory(b *testing.B, count int, size int) files.Directory {
+	directory := files.NewRootDirectory()
+	for i := 0; i < count; i++ {
+		content := strings.Repeat(fmt.Sprintf("line %d of the asset\n", i), size/20)
+		if err := directory.NewFile(paths.Of(fmt.Sprintf("assets/%d/asset-%d.txt", i%10, i))).Write(bytes.NewBufferString(content)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return directory
+}
+
+// benchmarkCompressors lists the compressors that are compared by the benchmarks
+var benchmarkCompressors = []struct {
+	name       string
+	compressor Compressor
+}{
+	{"tar", &Tar{}},
+	{"store", &Store{}},
+}
+
+func BenchmarkCompress(b *testing.B) {
+	directory := benchmarkDirectory(b, 200, 8*1024)
+	for _, entry := range benchmarkCompressors {
+		b.Run(entry.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := entry.compressor.Compress(context.Background(), directory, &bytes.Buffer{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecompress(b *testing.B) {
+	directory := benchmarkDirectory(b, 200, 8*1024)
+	for _, entry := range benchmarkCompressors {
+		buffer := &bytes.Buffer{}
+		if err := entry.compressor.Compress(context.Background(), directory, buffer); err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(entry.name, func(b *testing.B) {
+			b.SetBytes(int64(buffer.Len()))
+			for i := 0; i < b.N; i++ {
+				if _, err := entry.compressor.Decompress(context.Background(), bytes.NewReader(buffer.Bytes())); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
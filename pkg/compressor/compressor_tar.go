@@ -24,101 +24,295 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/flate"
-	"compress/gzip"
-	"github.com/homeport/pina-golada/pkg/files/paths"
+	"context"
+	"fmt"
 	"io"
-	"path/filepath"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
 )
 
-// Tar is an implementation of the compressor interface which compresses to .tar.gz files
-type Tar struct{}
+// gzipUnknownOS is the operating system written to gzip headers, as defined by RFC 1952
+const gzipUnknownOS = 255
 
-// Compress compresses the directory into the writer
-func (t *Tar) Compress(directory files.Directory, writer *bytes.Buffer) error {
-	gzipWriter, err := gzip.NewWriterLevel(writer, flate.BestCompression)
+// Tar is an implementation of the compressor interface which compresses to .tar.gz files. Archives are
+// reproducible, entries are sorted and modification times, owners and the gzip header are normalized.
+// Level is the gzip compression level, flate.BestCompression is used if it is zero. It is the pipeline of the
+// TarArchiver and the Gzip codec.
+type Tar struct {
+	Level int
+}
+
+// NewTar creates a tar compressor. Only the compression level can be configured.
+func NewTar(options Options) (Compressor, error) {
+	if err := options.checkKeys("tar"); err != nil {
+		return nil, err
+	}
+
+	level, err := options.flateLevel(flate.BestCompression)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	tarWriter := tar.NewWriter(gzipWriter)
+	return &Tar{Level: level}, nil
+}
 
-	files.WalkFileTree(directory, func(file files.File) {
-		buffer := &bytes.Buffer{}
-		if err := file.CopyContent(buffer); err != nil {
-			return
-		}
+// Metadata describes the tar compressor
+func (t *Tar) Metadata() Metadata {
+	return Metadata{
+		ID:                        "tar",
+		Description:               "gzip compressed tar archive",
+		Extension:                 ".tar.gz",
+		MediaType:                 "application/gzip",
+		PreservesPermissions:      true,
+		PreservesEmptyDirectories: true,
+	}
+}
 
-		tarHeader := &tar.Header{
-			Name:     filepath.ToSlash(file.AbsolutePath().String()),
-			Mode:     int64(file.PermissionSet()),
-			Size:     int64(buffer.Len()),
-			Typeflag: tar.TypeReg,
-		}
+// Compress compresses the directory into the writer
+func (t *Tar) Compress(ctx context.Context, directory files.Directory, writer io.Writer) error {
+	return t.pipeline().Compress(ctx, directory, writer)
+}
 
-		if err := tarWriter.WriteHeader(tarHeader); err != nil {
-			return
-		}
-		if _, err := tarWriter.Write(buffer.Bytes()); err != nil {
-			return
-		}
-	})
+// Decompress decompresses the reader into the directory
+func (t *Tar) Decompress(ctx context.Context, reader io.Reader) (files.Directory, error) {
+	return t.pipeline().Decompress(ctx, reader)
+}
 
-	files.WalkDirectoryTree(directory, func(d files.Directory) {
-		tarHeader := &tar.Header{
-			Name:     filepath.ToSlash(d.AbsolutePath().String()),
-			Mode:     int64(d.PermissionSet()),
-			Typeflag: tar.TypeDir,
+// pipeline returns the pipeline that implements the tar compressor
+func (t *Tar) pipeline() *Pipeline {
+	return &Pipeline{Archiver: &TarArchiver{}, Codec: &Gzip{Level: t.Level}}
+}
+
+// TarArchiver is an archiver that writes uncompressed, reproducible tar archives. Combine it with a codec to
+// compress the archive.
+type TarArchiver struct{}
+
+// NewTarArchiver creates a tar archiver, it cannot be configured
+func NewTarArchiver(options Options) (Compressor, error) {
+	if options.Level != 0 {
+		return nil, fmt.Errorf("archiver tar does not support a compression level")
+	}
+
+	if err := options.checkKeys("tar"); err != nil {
+		return nil, err
+	}
+
+	return &TarArchiver{}, nil
+}
+
+// Metadata describes the tar archiver
+func (t *TarArchiver) Metadata() Metadata {
+	return Metadata{
+		ID:                        "tar",
+		Description:               "tar archive",
+		Extension:                 ".tar",
+		MediaType:                 "application/x-tar",
+		PreservesPermissions:      true,
+		PreservesEmptyDirectories: true,
+	}
+}
+
+// Compress writes the directory as tar archive into the writer
+func (t *TarArchiver) Compress(ctx context.Context, directory files.Directory, writer io.Writer) error {
+	tarWriter := tar.NewWriter(writer)
+
+	if err := walkTree(ctx, directory, func(d files.Directory) error {
+		return tarWriter.WriteHeader(tarHeader(d.AbsolutePath(), d.PermissionSet(), 0, tar.TypeDir))
+	}, func(file files.File) error {
+		buffer := &bytes.Buffer{}
+		if err := file.CopyContent(buffer); err != nil {
+			return err
 		}
 
-		if err := tarWriter.WriteHeader(tarHeader); err != nil {
-			return
+		if err := tarWriter.WriteHeader(tarHeader(file.AbsolutePath(), file.PermissionSet(), buffer.Len(), tar.TypeReg)); err != nil {
+			return err
 		}
-	})
 
-	if err := gzipWriter.Close(); err != nil {
+		_, err := tarWriter.Write(buffer.Bytes())
+		return err
+	}); err != nil {
 		return err
 	}
 
 	return tarWriter.Close()
 }
 
-// Decompress decompresses the reader into the directory
-func (t *Tar) Decompress(reader io.Reader) (directory files.Directory, e error) {
-	root := files.NewRootDirectory()
+// tarHeader creates the header of an entry. Modification time and ownership are normalized, so the archive only
+// depends on the names, permissions and contents of the entries.
+func tarHeader(path paths.Path, mode os.FileMode, size int, typeFlag byte) *tar.Header {
+	return &tar.Header{
+		Name:     path.String(),
+		Mode:     tarMode(mode),
+		Size:     int64(size),
+		Typeflag: typeFlag,
+		ModTime:  time.Unix(0, 0),
+	}
+}
 
-	gzipReader, e := gzip.NewReader(reader)
-	if e != nil {
-		return root, e
+// tarMode returns the unix mode bits of the permission set, the type of the entry is defined by its type flag
+func tarMode(mode os.FileMode) int64 {
+	result := int64(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		result |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		result |= 02000
 	}
+	if mode&os.ModeSticky != 0 {
+		result |= 01000
+	}
+	return result
+}
 
-	tarReader := tar.NewReader(gzipReader)
+// defaultDirectoryMode is the mode of directories that an archive contains no entry for
+const defaultDirectoryMode = os.ModeDir | 0755
 
-	var foundError error
+// archiveDirectory is a directory entry of an archive, whose mode is applied once all entries were read
+type archiveDirectory struct {
+	path paths.Path
+	mode os.FileMode
+}
+
+// tarLink is a hard or symbolic link entry, which is replaced by a copy of its target once all entries were read
+type tarLink struct {
+	path     paths.Path
+	target   string
+	symbolic bool
+}
+
+// Decompress reads the tar archive of the reader into a new root directory. Archives of other tools, like GNU tar
+// and BSD tar, are read as well: PAX and GNU long names are resolved, directories without an entry are created,
+// entries may come in any order and the modes of directories are applied after their children were created.
+// Hard links and symbolic links to files within the archive become copies of their targets. Other symbolic
+// links and special files, like devices, are skipped as the directory tree cannot represent them.
+func (t *TarArchiver) Decompress(ctx context.Context, reader io.Reader) (files.Directory, error) {
+	root := files.NewRootDirectory()
+	tarReader := tar.NewReader(reader)
+
+	var directories []archiveDirectory
+	var links []tarLink
 	for {
-		header, bufferReaderError := tarReader.Next()
-		if bufferReaderError == io.EOF {
-			break
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
-		if bufferReaderError != nil {
-			foundError = bufferReaderError
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			return nil, err
+		}
 
-		if header.Typeflag == tar.TypeDir {
-			root.Directory(paths.Of(header.Name)).WithPermission(header.FileInfo().Mode())
-		} else {
-			if err := root.NewFile(paths.Of(header.Name)).WithPermission(header.FileInfo().Mode()).Write(tarReader); err != nil {
-				foundError = err
-				break
+		path, err := paths.Parse(header.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if path.Valid() {
+				root.NewDirectory(path)
 			}
+			directories = append(directories, archiveDirectory{path: path, mode: header.FileInfo().Mode()})
+
+		case tar.TypeReg, tar.TypeRegA, tar.TypeCont, tar.TypeGNUSparse:
+			file := root.NewFile(path)
+			if file == nil {
+				return nil, fmt.Errorf("invalid file entry %s", header.Name)
+			}
+
+			if err := file.WithPermission(header.FileInfo().Mode()).Write(tarReader); err != nil {
+				return nil, err
+			}
+
+		case tar.TypeLink, tar.TypeSymlink:
+			if !path.Valid() {
+				return nil, fmt.Errorf("invalid link entry %s", header.Name)
+			}
+			links = append(links, tarLink{path: path, target: header.Linkname, symbolic: header.Typeflag == tar.TypeSymlink})
 		}
 	}
 
-	if err := gzipReader.Close(); err != nil { // Close the gzip reader
+	if err := resolveLinks(root, links); err != nil {
 		return nil, err
 	}
 
-	return root, foundError
+	if err := walkTree(ctx, root, func(d files.Directory) error {
+		d.WithPermission(defaultDirectoryMode) // Directories with an entry get their mode below
+		return nil
+	}, func(files.File) error { return nil }); err != nil {
+		return nil, err
+	}
+
+	for _, directory := range directories {
+		if directory.path.Valid() {
+			root.Directory(directory.path).WithPermission(directory.mode)
+		} else {
+			root.WithPermission(directory.mode)
+		}
+	}
+
+	return root, nil
+}
+
+// resolveLinks copies the targets of the links to their paths. Links may refer to other links, so the links are
+// resolved until no more targets can be found. Hard links that remain unresolved are reported as error, symbolic
+// links that point outside of the archive, to directories or to missing files are skipped.
+func resolveLinks(root files.Directory, links []tarLink) error {
+	for len(links) > 0 {
+		var unresolved []tarLink
+		for _, link := range links {
+			target := linkTarget(root, link)
+			if target == nil {
+				unresolved = append(unresolved, link)
+				continue
+			}
+
+			content := &bytes.Buffer{}
+			if err := target.CopyContent(content); err != nil {
+				return err
+			}
+
+			if err := root.NewFile(link.path).WithPermission(target.PermissionSet()).Write(content); err != nil {
+				return err
+			}
+		}
+
+		if len(unresolved) == len(links) {
+			for _, link := range unresolved {
+				if !link.symbolic {
+					return fmt.Errorf("hard link %s refers to missing file %s", link.path.String(), link.target)
+				}
+			}
+			return nil
+		}
+
+		links = unresolved
+	}
+
+	return nil
+}
+
+// linkTarget returns the file the link refers to, or nil if it does not exist (yet). The targets of hard links
+// are relative to the root of the archive, those of symbolic links to the directory of the link.
+func linkTarget(root files.Directory, link tarLink) files.File {
+	target := link.target
+	if link.symbolic {
+		if strings.HasPrefix(target, "/") {
+			return nil
+		}
+		target = link.path.Parent().String() + "/" + target
+	}
+
+	path, err := paths.Parse(target)
+	if err != nil || !path.Valid() {
+		return nil
+	}
+
+	return root.File(path)
 }
@@ -21,7 +21,13 @@
 package compressor
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
@@ -57,9 +63,9 @@ var _ = Describe("should compress files correctly", func() {
 		tarCompressor := registry.Find("tar")
 		Expect(tarCompressor).To(Not(BeNil()))
 
-		Expect(tarCompressor.Compress(directory, buffer)).To(BeNil())
+		Expect(tarCompressor.Compress(context.Background(), directory, buffer)).To(BeNil())
 
-		result, e := tarCompressor.Decompress(buffer)
+		result, e := tarCompressor.Decompress(context.Background(), buffer)
 		Expect(e).To(BeNil())
 		Expect(result).To(Not(BeNil()))
 
@@ -82,10 +88,10 @@ var _ = Describe("should compress files correctly", func() {
 
 		tarCompressor := &Tar{}
 
-		err = tarCompressor.Compress(directory, buffer)
+		err = tarCompressor.Compress(context.Background(), directory, buffer)
 		Expect(err).ToNot(HaveOccurred())
 
-		result, err := tarCompressor.Decompress(buffer)
+		result, err := tarCompressor.Decompress(context.Background(), buffer)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(result).ToNot(BeNil())
 
@@ -110,4 +116,115 @@ var _ = Describe("should compress files correctly", func() {
 		Expect(dirByPath(result, "subdirectory-1").PermissionSet()).
 			To(BeEquivalentTo(dirByPath(directory, "subdirectory-1").PermissionSet()))
 	})
+
+	_ = It("should refuse entries climbing above the root", func() {
+		gzipWriter := gzip.NewWriter(buffer)
+		tarWriter := tar.NewWriter(gzipWriter)
+		Expect(tarWriter.WriteHeader(&tar.Header{Name: "../../etc/passwd", Mode: 0644, Size: 4, Typeflag: tar.TypeReg})).To(BeNil())
+		_, e := tarWriter.Write([]byte("evil"))
+		Expect(e).To(BeNil())
+		Expect(tarWriter.Close()).To(BeNil())
+		Expect(gzipWriter.Close()).To(BeNil())
+
+		_, e = (&Tar{}).Decompress(context.Background(), buffer)
+		Expect(errors.Is(e, paths.ErrOutsideRoot)).To(BeTrue())
+	})
+})
+
+var _ = Describe("should decompress tar archives of other tools", func() {
+	// decompressFixture reads the named archive of the tar-archives test assets
+	decompressFixture := func(name string) files.Directory {
+		file, e := os.Open(filepath.Join("../../assets/tests/tar-archives", name))
+		Expect(e).ToNot(HaveOccurred())
+		defer file.Close()
+
+		result, e := (&TarArchiver{}).Decompress(context.Background(), file)
+		Expect(e).ToNot(HaveOccurred())
+		return result
+	}
+
+	// contentOf returns the content of the file at the path, which has to exist
+	contentOf := func(directory files.Directory, path string) string {
+		file := directory.File(paths.Of(path))
+		Expect(file).ToNot(BeNil(), path)
+
+		content := &bytes.Buffer{}
+		Expect(file.CopyContent(content)).To(BeNil())
+		return content.String()
+	}
+
+	_ = It("should create directories that have no entry", func() {
+		result := decompressFixture("gnu-missing-directories.tar")
+		Expect(contentOf(result, "a/b/c/deep.txt")).To(Equal("deep\n"))
+		Expect(contentOf(result, "a/top.txt")).To(Equal("top\n"))
+		Expect(result.Directory(paths.Of("a/b")).PermissionSet()).To(Equal(defaultDirectoryMode))
+	})
+
+	_ = It("should apply the modes of directories after their children", func() {
+		result := decompressFixture("gnu-directory-modes.tar")
+		Expect(contentOf(result, "locked/inner/file.txt")).To(Equal("locked\n"))
+		Expect(result.Directory(paths.Of("locked")).PermissionSet().Perm()).To(BeEquivalentTo(0500))
+		Expect(result.Directory(paths.Of("locked/inner")).PermissionSet().Perm()).To(BeEquivalentTo(0700))
+		Expect(result.File(paths.Of("locked/inner/file.txt")).PermissionSet().Perm()).To(BeEquivalentTo(0644))
+
+		Expect(contentOf(result, "late/file.txt")).To(Equal("late\n"))
+		Expect(result.Directory(paths.Of("late")).PermissionSet().Perm()).To(BeEquivalentTo(0750))
+	})
+
+	_ = It("should keep empty directories and the mode of the root entry", func() {
+		result := decompressFixture("bsdtar-empty-directories.tar")
+		Expect(result.PermissionSet().Perm()).To(BeEquivalentTo(0755))
+		Expect(result.Directory(paths.Of("empty")).PermissionSet().Perm()).To(BeEquivalentTo(0711))
+		Expect(result.Directory(paths.Of("parent/empty-child"))).ToNot(BeNil())
+		Expect(contentOf(result, "parent/file.txt")).To(Equal("x\n"))
+	})
+
+	for _, name := range []string{"gnu-hard-links.tar", "bsdtar-hard-links.tar"} {
+		name := name
+
+		_ = It("should copy the targets of hard links in "+name, func() {
+			result := decompressFixture(name)
+			Expect(contentOf(result, "original.txt")).To(Equal("linked content\n"))
+			Expect(contentOf(result, "dir/link.txt")).To(Equal("linked content\n"))
+			Expect(result.File(paths.Of("dir/link.txt")).PermissionSet().Perm()).To(BeEquivalentTo(0640))
+		})
+	}
+
+	_ = It("should refuse hard links to missing files", func() {
+		buffer := &bytes.Buffer{}
+		tarWriter := tar.NewWriter(buffer)
+		Expect(tarWriter.WriteHeader(&tar.Header{Name: "link.txt", Linkname: "missing.txt", Typeflag: tar.TypeLink})).To(BeNil())
+		Expect(tarWriter.Close()).To(BeNil())
+
+		_, e := (&TarArchiver{}).Decompress(context.Background(), buffer)
+		Expect(e).To(HaveOccurred())
+	})
+
+	for _, name := range []string{"gnu-long-names.tar", "pax-long-names.tar"} {
+		name := name
+
+		_ = It("should resolve the long names of "+name, func() {
+			result := decompressFixture(name)
+			Expect(contentOf(result, "directory-with-a-rather-long-name-1/directory-with-a-rather-long-name-2/"+
+				"directory-with-a-rather-long-name-3/file-with-a-long-name-to-exceed-one-hundred-characters.txt")).To(Equal("long\n"))
+			Expect(contentOf(result, "ünïcödé-名前.txt")).To(Equal("ünïcödé\n"))
+			Expect(result.Files()).To(HaveLen(1))
+		})
+	}
+
+	_ = It("should copy symbolic links to files within the archive and skip the others", func() {
+		result := decompressFixture("gnu-symbolic-links.tar")
+		Expect(contentOf(result, "relative.txt")).To(Equal("target\n"))
+
+		for _, skipped := range []string{"absolute.txt", "dangling.txt", "directory-link", "up.txt"} {
+			Expect(result.File(paths.Of(skipped))).To(BeNil(), skipped)
+		}
+	})
+
+	_ = It("should ignore PAX global headers", func() {
+		result := decompressFixture("git-archive.tar")
+		Expect(contentOf(result, "README.md")).To(Equal("readme\n"))
+		Expect(contentOf(result, "docs/guide.md")).To(Equal("guide\n"))
+		Expect(result.File(paths.Of("pax_global_header"))).To(BeNil())
+	})
 })
@@ -0,0 +1,120 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+// errFailingWriter is returned by every write to a failingWriter
+var errFailingWriter = errors.New("failing writer")
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+// Write fails
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errFailingWriter
+}
+
+// legacyStore is a compressor that implements the legacy, buffer based interface
+type legacyStore struct{}
+
+// Compress compresses the directory into the buffer using the store compressor
+func (legacyStore) Compress(directory files.Directory, writer *bytes.Buffer) error {
+	return (&Store{}).Compress(context.Background(), directory, writer)
+}
+
+// Decompress decompresses the reader using the store compressor
+func (legacyStore) Decompress(reader io.Reader) (files.Directory, error) {
+	return (&Store{}).Decompress(context.Background(), reader)
+}
+
+var _ = Describe("should stream and propagate errors", func() {
+	var directory files.Directory
+
+	compressors := map[string]Compressor{
+		"tar":     &Tar{},
+		"zip":     &Zip{},
+		"store":   &Store{},
+		"indexed": &Indexed{},
+		"legacy":  Adapt(legacyStore{}),
+
+		"tar+zlib": &Pipeline{Archiver: &TarArchiver{}, Codec: &Zlib{}},
+		"zip+none": &Pipeline{Archiver: &Zip{}, Codec: &Identity{}},
+		"parallel": &Parallel{BlockSize: 1024},
+	}
+
+	_ = BeforeEach(func() {
+		directory = files.NewRootDirectory()
+		Expect(directory.NewFile(paths.Of("usr/homeport/test.txt")).Write(bytes.NewBufferString("test"))).To(BeNil())
+	})
+
+	for name, compressor := range compressors {
+		name, compressor := name, compressor
+
+		_ = It("should report write errors of "+name, func() {
+			Expect(errors.Is(compressor.Compress(context.Background(), directory, failingWriter{}), errFailingWriter)).To(BeTrue())
+		})
+
+		_ = It("should stop compressing with "+name+" once the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			Expect(errors.Is(compressor.Compress(ctx, directory, &bytes.Buffer{}), context.Canceled)).To(BeTrue())
+		})
+
+		_ = It("should stop decompressing with "+name+" once the context is canceled", func() {
+			buffer := &bytes.Buffer{}
+			Expect(compressor.Compress(context.Background(), directory, buffer)).To(BeNil())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			result, e := compressor.Decompress(ctx, buffer)
+			Expect(errors.Is(e, context.Canceled)).To(BeTrue())
+			Expect(result).To(BeNil())
+		})
+
+		_ = It("should round trip through an io.Writer with "+name, func() {
+			reader, writer := io.Pipe()
+			go func() {
+				_ = writer.CloseWithError(compressor.Compress(context.Background(), directory, writer))
+			}()
+
+			result, e := compressor.Decompress(context.Background(), reader)
+			Expect(e).To(BeNil())
+
+			content := &bytes.Buffer{}
+			Expect(result.File(paths.Of("usr/homeport/test.txt")).CopyContent(content)).To(BeNil())
+			Expect(content.String()).To(BeEquivalentTo("test"))
+		})
+	}
+})
@@ -0,0 +1,249 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+// Zip is an implementation of the compressor interface which compresses to .zip files. Permissions are kept in
+// the external attributes of each entry and every directory gets its own entry, so empty directories survive.
+//
+// Level is the deflate compression level, flate.BestCompression is used if it is zero.
+//
+// Store decides per file whether it is stored without compression, e.g. for already compressed images. If it is
+// nil or returns false, the file is deflated unless deflating does not make it smaller.
+type Zip struct {
+	Level int
+	Store func(file files.File) bool
+}
+
+// NewZip creates a zip compressor. Besides the compression level, the option "store" takes a list of file
+// extensions separated by "|", e.g. ".png|.jpg", whose files are stored without compression.
+func NewZip(options Options) (Compressor, error) {
+	if err := options.checkKeys("zip", "store"); err != nil {
+		return nil, err
+	}
+
+	level, err := options.flateLevel(flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Zip{Level: level}
+	if extensions, ok := options.Values["store"]; ok {
+		stored := map[string]bool{}
+		for _, extension := range strings.Split(extensions, "|") {
+			stored[strings.ToLower(extension)] = true
+		}
+
+		result.Store = func(file files.File) bool {
+			return stored[strings.ToLower(file.Name().Ext())]
+		}
+	}
+
+	return result, nil
+}
+
+// Metadata describes the zip compressor
+func (z *Zip) Metadata() Metadata {
+	return Metadata{
+		ID:                        "zip",
+		Description:               "zip archive",
+		Extension:                 ".zip",
+		MediaType:                 "application/zip",
+		PreservesPermissions:      true,
+		PreservesEmptyDirectories: true,
+	}
+}
+
+// Compress compresses the directory into the writer
+func (z *Zip) Compress(ctx context.Context, directory files.Directory, writer io.Writer) error {
+	level, err := Options{Level: z.Level}.flateLevel(flate.BestCompression)
+	if err != nil {
+		return err
+	}
+
+	zipWriter := zip.NewWriter(writer)
+	zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, level)
+	})
+
+	if err := walkTree(ctx, directory, func(d files.Directory) error {
+		header := &zip.FileHeader{Name: zipName(d.AbsolutePath()) + "/"}
+		header.SetMode(d.PermissionSet() | os.ModeDir)
+
+		_, err := zipWriter.CreateHeader(header)
+		return err
+	}, func(file files.File) error {
+		return z.compressFile(zipWriter, file, level)
+	}); err != nil {
+		return err
+	}
+
+	return zipWriter.Close()
+}
+
+// compressFile writes the file as a stored or deflated entry, whichever is smaller. The size of the deflated
+// content is counted up front, the zip writer deflates the content of the entry itself
+func (z *Zip) compressFile(zipWriter *zip.Writer, file files.File, level int) error {
+	content := &bytes.Buffer{}
+	if err := file.CopyContent(content); err != nil {
+		return err
+	}
+
+	header := &zip.FileHeader{Name: zipName(file.AbsolutePath()), Method: zip.Store}
+	header.SetMode(file.PermissionSet())
+
+	if z.Store == nil || !z.Store(file) {
+		counter := &countingWriter{}
+		if err := deflate(counter, content.Bytes(), level); err != nil {
+			return err
+		}
+
+		if counter.count < int64(content.Len()) {
+			header.Method = zip.Deflate
+		}
+	}
+
+	entryWriter, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = entryWriter.Write(content.Bytes())
+	return err
+}
+
+// Decompress decompresses the reader into the directory. Directories without an entry, as in zips of tools that
+// only store files, get the default directory mode
+func (z *Zip) Decompress(ctx context.Context, reader io.Reader) (directory files.Directory, e error) {
+	content, e := ioutil.ReadAll(reader)
+	if e != nil {
+		return nil, e
+	}
+
+	zipReader, e := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if e != nil {
+		return nil, e
+	}
+
+	root := files.NewRootDirectory()
+	var directories []archiveDirectory
+	for _, entry := range zipReader.File {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		path, e := paths.Parse(entry.Name)
+		if e != nil {
+			return nil, e
+		}
+
+		if entry.FileInfo().IsDir() {
+			if path.Valid() {
+				root.NewDirectory(path)
+				directories = append(directories, archiveDirectory{path: path, mode: entry.Mode()})
+			} else if entry.Name != "./" && entry.Name != paths.Separator {
+				return nil, fmt.Errorf("invalid directory entry %s", entry.Name)
+			}
+			continue
+		}
+
+		if err := decompressZipEntry(root, path, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := walkTree(ctx, root, func(d files.Directory) error {
+		d.WithPermission(defaultDirectoryMode) // Directories with an entry get their mode below
+		return nil
+	}, func(files.File) error { return nil }); err != nil {
+		return nil, err
+	}
+
+	for _, dir := range directories {
+		root.Directory(dir.path).WithPermission(dir.mode)
+	}
+
+	return root, nil
+}
+
+// decompressZipEntry writes the content of the zip entry into a new file at the path
+func decompressZipEntry(root files.Directory, path paths.Path, entry *zip.File) error {
+	file := root.NewFile(path)
+	if file == nil {
+		return fmt.Errorf("invalid file entry %s", entry.Name)
+	}
+
+	entryReader, e := entry.Open()
+	if e != nil {
+		return e
+	}
+
+	if err := file.WithPermission(entry.Mode()).Write(entryReader); err != nil {
+		_ = entryReader.Close()
+		return err
+	}
+
+	return entryReader.Close()
+}
+
+// zipName returns the name of the entry at the path, zip entry names must not start with a slash
+func zipName(path paths.Path) string {
+	return strings.TrimPrefix(path.String(), paths.Separator)
+}
+
+// deflate writes the content deflated at the level into the writer
+func deflate(writer io.Writer, content []byte, level int) error {
+	flateWriter, e := flate.NewWriter(writer, level)
+	if e != nil {
+		return e
+	}
+
+	if _, e := flateWriter.Write(content); e != nil {
+		return e
+	}
+
+	return flateWriter.Close()
+}
+
+// countingWriter is a writer that only counts the bytes written to it
+type countingWriter struct {
+	count int64
+}
+
+// Write counts the length of the slice
+func (c *countingWriter) Write(p []byte) (n int, err error) {
+	c.count += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,204 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compressor
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should compress zip files correctly", func() {
+	var (
+		directory files.Directory
+		buffer    *bytes.Buffer
+	)
+
+	_ = BeforeEach(func() {
+		directory = files.NewRootDirectory()
+		buffer = &bytes.Buffer{}
+	})
+
+	fileByPath := func(ref files.Directory, path string) files.File {
+		file := ref.File(paths.Of(path))
+		Expect(file).ToNot(BeNil())
+		return file
+	}
+
+	dirByPath := func(ref files.Directory, path string) files.Directory {
+		dir := ref.Directory(paths.Of(path))
+		Expect(dir).ToNot(BeNil())
+		return dir
+	}
+
+	contentOf := func(file files.File) string {
+		content := &bytes.Buffer{}
+		Expect(file.CopyContent(content)).To(BeNil())
+		return content.String()
+	}
+
+	_ = It("should be registered in the default registry", func() {
+		Expect(DefaultRegistry.Find("zip")).ToNot(BeNil())
+	})
+
+	_ = It("should compress and decompress zips correctly", func() {
+		Expect(directory.NewFile(paths.Of("usr/homeport/home/testA.go")).Write(bytes.NewBufferString("testA"))).To(BeNil())
+		Expect(directory.NewFile(paths.Of("usr/homeport/home/testB.go")).Write(bytes.NewBufferString("testB"))).To(BeNil())
+
+		zipCompressor := &Zip{}
+		Expect(zipCompressor.Compress(context.Background(), directory, buffer)).To(BeNil())
+
+		result, e := zipCompressor.Decompress(context.Background(), buffer)
+		Expect(e).To(BeNil())
+		Expect(result).To(Not(BeNil()))
+
+		Expect(contentOf(fileByPath(result, "usr/homeport/home/testA.go"))).To(BeEquivalentTo("testA"))
+		Expect(contentOf(fileByPath(result, "usr/homeport/home/testB.go"))).To(BeEquivalentTo("testB"))
+	})
+
+	_ = It("should preserve all file permissions when compressing and decompressing", func() {
+		Expect(files.LoadFromDisk(directory, "../../assets/tests/issue-35")).ToNot(HaveOccurred())
+
+		zipCompressor := &Zip{}
+		Expect(zipCompressor.Compress(context.Background(), directory, buffer)).ToNot(HaveOccurred())
+
+		result, err := zipCompressor.Decompress(context.Background(), buffer)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).ToNot(BeNil())
+
+		Expect(fileByPath(result, "root.txt").PermissionSet()).
+			To(BeEquivalentTo(fileByPath(directory, "root.txt").PermissionSet()))
+
+		Expect(fileByPath(result, "subdirectory-1/nested-directory-1/example.txt").PermissionSet()).
+			To(BeEquivalentTo(fileByPath(directory, "subdirectory-1/nested-directory-1/example.txt").PermissionSet()))
+
+		Expect(dirByPath(result, "subdirectory-1").PermissionSet().Perm()).
+			To(BeEquivalentTo(dirByPath(directory, "subdirectory-1").PermissionSet().Perm()))
+	})
+
+	_ = It("should keep empty directories", func() {
+		directory.NewDirectory(paths.Of("usr/empty")).WithPermission(os.ModeDir | 0700)
+
+		zipCompressor := &Zip{}
+		Expect(zipCompressor.Compress(context.Background(), directory, buffer)).To(BeNil())
+
+		result, e := zipCompressor.Decompress(context.Background(), buffer)
+		Expect(e).To(BeNil())
+
+		empty := dirByPath(result, "usr/empty")
+		Expect(empty.Files()).To(BeEmpty())
+		Expect(empty.Directories()).To(BeEmpty())
+		Expect(empty.PermissionSet().Perm()).To(BeEquivalentTo(0700))
+	})
+
+	_ = It("should store or deflate each entry", func() {
+		Expect(directory.NewFile(paths.Of("text.txt")).Write(bytes.NewBufferString(strings.Repeat("text", 1024)))).To(BeNil())
+		Expect(directory.NewFile(paths.Of("image.png")).Write(bytes.NewBufferString(strings.Repeat("png", 1024)))).To(BeNil())
+		Expect(directory.NewFile(paths.Of("tiny.txt")).Write(bytes.NewBufferString("a"))).To(BeNil())
+
+		zipCompressor := &Zip{Store: func(file files.File) bool {
+			return file.Name().Ext() == ".png"
+		}}
+		Expect(zipCompressor.Compress(context.Background(), directory, buffer)).To(BeNil())
+
+		zipReader, e := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+		Expect(e).To(BeNil())
+
+		methods := map[string]uint16{}
+		for _, entry := range zipReader.File {
+			methods[entry.Name] = entry.Method
+		}
+		Expect(methods).To(Equal(map[string]uint16{
+			"text.txt":  zip.Deflate,
+			"image.png": zip.Store,
+			"tiny.txt":  zip.Store,
+		}))
+
+		result, e := zipCompressor.Decompress(context.Background(), buffer)
+		Expect(e).To(BeNil())
+		Expect(contentOf(fileByPath(result, "text.txt"))).To(BeEquivalentTo(strings.Repeat("text", 1024)))
+		Expect(contentOf(fileByPath(result, "image.png"))).To(BeEquivalentTo(strings.Repeat("png", 1024)))
+	})
+
+	_ = It("should skip root directory entries", func() {
+		zipWriter := zip.NewWriter(buffer)
+		for _, name := range []string{"./", "/", "usr/"} {
+			_, e := zipWriter.Create(name)
+			Expect(e).To(BeNil())
+		}
+		Expect(zipWriter.Close()).To(BeNil())
+
+		result, e := (&Zip{}).Decompress(context.Background(), buffer)
+		Expect(e).To(BeNil())
+		Expect(result.Directories()).To(HaveLen(1))
+		Expect(dirByPath(result, "usr")).ToNot(BeNil())
+	})
+
+	_ = It("should create directories without an entry with the default mode", func() {
+		zipWriter := zip.NewWriter(buffer)
+		header := &zip.FileHeader{Name: "a/"}
+		header.SetMode(os.ModeDir | 0700)
+		_, e := zipWriter.CreateHeader(header)
+		Expect(e).To(BeNil())
+		entryWriter, e := zipWriter.Create("a/b/c.txt")
+		Expect(e).To(BeNil())
+		_, e = entryWriter.Write([]byte("c"))
+		Expect(e).To(BeNil())
+		Expect(zipWriter.Close()).To(BeNil())
+
+		result, e := (&Zip{}).Decompress(context.Background(), buffer)
+		Expect(e).To(BeNil())
+		Expect(dirByPath(result, "a").PermissionSet()).To(Equal(os.ModeDir | 0700))
+		Expect(dirByPath(result, "a/b").PermissionSet()).To(Equal(defaultDirectoryMode))
+		Expect(contentOf(fileByPath(result, "a/b/c.txt"))).To(BeEquivalentTo("c"))
+	})
+
+	_ = It("should refuse file entries without a name", func() {
+		zipWriter := zip.NewWriter(buffer)
+		_, e := zipWriter.Create("")
+		Expect(e).To(BeNil())
+		Expect(zipWriter.Close()).To(BeNil())
+
+		_, e = (&Zip{}).Decompress(context.Background(), buffer)
+		Expect(e).To(HaveOccurred())
+	})
+
+	_ = It("should refuse entries climbing above the root", func() {
+		zipWriter := zip.NewWriter(buffer)
+		entryWriter, e := zipWriter.Create("../../etc/passwd")
+		Expect(e).To(BeNil())
+		_, e = entryWriter.Write([]byte("evil"))
+		Expect(e).To(BeNil())
+		Expect(zipWriter.Close()).To(BeNil())
+
+		_, e = (&Zip{}).Decompress(context.Background(), buffer)
+		Expect(errors.Is(e, paths.ErrOutsideRoot)).To(BeTrue())
+	})
+})
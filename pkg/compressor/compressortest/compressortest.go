@@ -0,0 +1,624 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package compressortest implements a behavioural test suite for implementations of the compressor interface.
+// It covers the edge cases that ad-hoc round trip tests tend to miss, like empty files and directories, deep
+// nesting, unusual permissions, unicode names, cancellation, corrupt input and malformed entry names.
+//
+// With the testing package, every case runs as subtest:
+//
+//	func TestCustom(t *testing.T) {
+//		compressortest.Run(t, &Custom{})
+//	}
+//
+// Other test frameworks can check the cases one by one, or all at once using Test.
+package compressortest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/homeport/pina-golada/pkg/compressor"
+	"github.com/homeport/pina-golada/pkg/files"
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+// Case is a behaviour that every compressor has to show. Check returns an error describing the deviation.
+type Case struct {
+	Name  string
+	Check func(c compressor.Compressor) error
+}
+
+// errFailingWriter is returned by every write to a failingWriter
+var errFailingWriter = errors.New("failing writer")
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+// Write fails
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errFailingWriter
+}
+
+// Cases returns the cases of the suite
+func Cases() []Case {
+	return []Case{
+		{"round trips an empty directory", roundTrip(func(root files.Directory) {})},
+		{"round trips empty files", roundTrip(func(root files.Directory) {
+			file(root, "empty.txt", 0644, nil)
+			file(root, "nested/empty", 0644, nil)
+		})},
+		{"round trips empty directories", roundTrip(func(root files.Directory) {
+			directory(root, "empty", 0755)
+			directory(root, "parent/empty/child", 0755)
+			file(root, "parent/file.txt", 0644, []byte("next to an empty directory"))
+		})},
+		{"round trips deeply nested files", roundTrip(func(root files.Directory) {
+			path := ""
+			for depth := 0; depth < 64; depth++ {
+				path += fmt.Sprintf("level-%d/", depth)
+			}
+			file(root, path+"deep.txt", 0644, []byte("deep"))
+		})},
+		{"round trips unusual permissions", roundTrip(func(root files.Directory) {
+			file(root, "read-only", 0400, []byte("read-only"))
+			file(root, "private", 0600, []byte("private"))
+			file(root, "executable", 0755, []byte("#!/bin/sh\n"))
+			file(root, "everyone", 0777, []byte("everyone"))
+			file(root, "none", 0000, []byte("none"))
+			directory(root, "private-directory", 0700)
+			directory(root, "traversable/only", 0711)
+			directory(root, "locked", 0500)
+		})},
+		{"round trips unicode names", roundTrip(func(root files.Directory) {
+			file(root, "Größe.txt", 0644, []byte("umlauts"))
+			file(root, "日本語/ファイル.md", 0644, []byte("japanese"))
+			file(root, "emoji 🎉/party 🎈.txt", 0644, []byte("emoji"))
+			file(root, "spaces in names/and-dashes_and.dots.txt", 0644, []byte("spaces"))
+			file(root, "ελληνικά/кириллица/עברית.txt", 0644, []byte("scripts"))
+		})},
+		{"round trips binary content", roundTrip(func(root files.Directory) {
+			all := make([]byte, 256)
+			for i := range all {
+				all[i] = byte(i)
+			}
+			file(root, "bytes.bin", 0644, all)
+			file(root, "zeros.bin", 0644, make([]byte, 4096))
+			file(root, "no-newline.txt", 0644, []byte("no trailing newline"))
+		})},
+		{"round trips large files", roundTrip(func(root files.Directory) {
+			random := rand.New(rand.NewSource(1))
+			content := make([]byte, 3*1024*1024+17)
+			_, _ = random.Read(content)
+			file(root, "random.bin", 0644, content)
+			file(root, "repeated.txt", 0644, bytes.Repeat([]byte("repeated content\n"), 256*1024))
+		})},
+		{"round trips many files", roundTrip(func(root files.Directory) {
+			for i := 0; i < 1000; i++ {
+				file(root, fmt.Sprintf("dir-%d/file-%d.txt", i%25, i), 0644, []byte(fmt.Sprintf("file %d", i)))
+			}
+		})},
+		{"decompresses readers returning one byte at a time", checkOneByteReader},
+		{"leaves the compressed directory unchanged", checkUnchanged},
+		{"creates identical output for identical trees", checkReproducible},
+		{"reports write errors", checkWriteErrors},
+		{"stops compressing once the context is canceled", checkCanceledCompress},
+		{"stops decompressing once the context is canceled", checkCanceledDecompress},
+		{"rejects truncated input", checkTruncated},
+		{"rejects garbage input", checkGarbage},
+		{"rejects entries with malformed names", checkMalformedNames},
+	}
+}
+
+// Test runs all cases against the compressor and returns an error listing the failed ones
+func Test(c compressor.Compressor) error {
+	var failures []string
+	for _, testCase := range Cases() {
+		if err := check(testCase, c); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", testCase.Name, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d cases failed:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+
+	return nil
+}
+
+// Run runs every case against the compressor as subtest of t
+func Run(t *testing.T, c compressor.Compressor) {
+	for _, testCase := range Cases() {
+		testCase := testCase
+		t.Run(testCase.Name, func(t *testing.T) {
+			if err := check(testCase, c); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// check runs the case and turns panics of the compressor into errors
+func check(testCase Case, c compressor.Compressor) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+
+	return testCase.Check(c)
+}
+
+// file creates a file with the permission and content below the root
+func file(root files.Directory, path string, permission os.FileMode, content []byte) {
+	_ = root.NewFile(paths.Of(path)).WithPermission(permission).Write(bytes.NewReader(content))
+	setParentPermissions(root, path)
+}
+
+// directory creates a directory with the permission below the root
+func directory(root files.Directory, path string, permission os.FileMode) {
+	root.NewDirectory(paths.Of(path)).WithPermission(permission)
+	setParentPermissions(root, path)
+}
+
+// setParentPermissions sets the permission of all parent directories of the path that have none
+func setParentPermissions(root files.Directory, path string) {
+	parent := root
+	elements := strings.Split(path, "/")
+	for _, name := range elements[:len(elements)-1] {
+		parent = parent.Directory(paths.Of(name))
+		if parent.PermissionSet() == 0 {
+			parent.WithPermission(0755)
+		}
+	}
+}
+
+// roundTrip returns a check that compresses the tree created by build and compares the decompressed tree with it
+func roundTrip(build func(root files.Directory)) func(c compressor.Compressor) error {
+	return func(c compressor.Compressor) error {
+		root := files.NewRootDirectory().WithPermission(0755)
+		build(root)
+
+		buffer := &bytes.Buffer{}
+		if err := c.Compress(context.Background(), root, buffer); err != nil {
+			return fmt.Errorf("compress: %w", err)
+		}
+
+		result, err := c.Decompress(context.Background(), buffer)
+		if err != nil {
+			return fmt.Errorf("decompress: %w", err)
+		}
+
+		return compare(root, result)
+	}
+}
+
+// compare returns an error describing the first difference of the directories, ignoring the permission of the
+// root directory itself
+func compare(expected files.Directory, actual files.Directory) error {
+	if actual == nil {
+		return errors.New("decompressed directory is nil")
+	}
+
+	expectedEntries, err := entries(expected)
+	if err != nil {
+		return fmt.Errorf("expected tree: %w", err)
+	}
+
+	actualEntries, err := entries(actual)
+	if err != nil {
+		return fmt.Errorf("decompressed tree: %w", err)
+	}
+
+	for _, path := range sortedKeys(expectedEntries) {
+		actualEntry, ok := actualEntries[path]
+		if !ok {
+			return fmt.Errorf("%s is missing", path)
+		}
+
+		if expectedEntry := expectedEntries[path]; expectedEntry != actualEntry {
+			return fmt.Errorf("%s differs, expected %s but got %s", path, expectedEntry, actualEntry)
+		}
+	}
+
+	for _, path := range sortedKeys(actualEntries) {
+		if _, ok := expectedEntries[path]; !ok {
+			return fmt.Errorf("%s is unexpected", path)
+		}
+	}
+
+	return nil
+}
+
+// entry describes a file or directory of a tree
+type entry struct {
+	directory  bool
+	permission os.FileMode
+	size       int
+	content    string
+}
+
+// String describes the entry
+func (e entry) String() string {
+	if e.directory {
+		return fmt.Sprintf("directory with permission %v", e.permission)
+	}
+
+	content := e.content
+	if len(content) > 32 {
+		content = content[:32] + "..."
+	}
+	return fmt.Sprintf("file with permission %v and %d bytes %q", e.permission, e.size, content)
+}
+
+// entries returns the entries of the tree by their path relative to the root of the tree
+func entries(root files.Directory) (map[string]entry, error) {
+	result := map[string]entry{}
+
+	var walk func(directory files.Directory, prefix string) error
+	walk = func(directory files.Directory, prefix string) error {
+		for _, child := range directory.Directories() {
+			path := prefix + child.Name().String()
+			result[path] = entry{directory: true, permission: child.PermissionSet().Perm()}
+
+			if err := walk(child, path+"/"); err != nil {
+				return err
+			}
+		}
+
+		for _, child := range directory.Files() {
+			content := &bytes.Buffer{}
+			if err := child.CopyContent(content); err != nil {
+				return fmt.Errorf("%s: %w", prefix+child.Name().String(), err)
+			}
+
+			result[prefix+child.Name().String()] = entry{
+				permission: child.PermissionSet().Perm(),
+				size:       content.Len(),
+				content:    content.String(),
+			}
+		}
+
+		return nil
+	}
+
+	return result, walk(root, "/")
+}
+
+// sortedKeys returns the paths of the entries in order
+func sortedKeys(entries map[string]entry) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// sampleTree returns a small tree with files and directories
+func sampleTree() files.Directory {
+	root := files.NewRootDirectory().WithPermission(0755)
+	file(root, "a/1.txt", 0644, []byte(strings.Repeat("first file\n", 100)))
+	file(root, "a/nested/2.txt", 0600, []byte("second file"))
+	file(root, "b.txt", 0644, []byte("third file"))
+	directory(root, "c", 0755)
+	return root
+}
+
+// compressed returns the sample tree compressed by the compressor
+func compressed(c compressor.Compressor) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	if err := c.Compress(context.Background(), sampleTree(), buffer); err != nil {
+		return nil, fmt.Errorf("compress: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// checkOneByteReader decompresses a reader that only returns one byte per read
+func checkOneByteReader(c compressor.Compressor) error {
+	data, err := compressed(c)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.Decompress(context.Background(), iotest.OneByteReader(bytes.NewReader(data)))
+	if err != nil {
+		return fmt.Errorf("decompress: %w", err)
+	}
+
+	return compare(sampleTree(), result)
+}
+
+// checkUnchanged compresses the sample tree and compares it with a fresh copy afterwards
+func checkUnchanged(c compressor.Compressor) error {
+	tree := sampleTree()
+	if err := c.Compress(context.Background(), tree, &bytes.Buffer{}); err != nil {
+		return fmt.Errorf("compress: %w", err)
+	}
+
+	return compare(sampleTree(), tree)
+}
+
+// checkReproducible compresses equal trees that were built in different orders
+func checkReproducible(c compressor.Compressor) error {
+	first, err := compressed(c)
+	if err != nil {
+		return err
+	}
+
+	reversed := files.NewRootDirectory().WithPermission(0755)
+	directory(reversed, "c", 0755)
+	file(reversed, "b.txt", 0644, []byte("third file"))
+	file(reversed, "a/nested/2.txt", 0600, []byte("second file"))
+	file(reversed, "a/1.txt", 0644, []byte(strings.Repeat("first file\n", 100)))
+
+	second := &bytes.Buffer{}
+	if err := c.Compress(context.Background(), reversed, second); err != nil {
+		return fmt.Errorf("compress: %w", err)
+	}
+
+	if !bytes.Equal(first, second.Bytes()) {
+		return errors.New("equal trees built in different orders were compressed differently")
+	}
+
+	return nil
+}
+
+// checkWriteErrors compresses into a writer that fails every write
+func checkWriteErrors(c compressor.Compressor) error {
+	if err := c.Compress(context.Background(), sampleTree(), failingWriter{}); !errors.Is(err, errFailingWriter) {
+		return fmt.Errorf("expected the error of the writer, got %v", err)
+	}
+
+	return nil
+}
+
+// checkCanceledCompress compresses with a canceled context
+func checkCanceledCompress(c compressor.Compressor) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := c.Compress(ctx, sampleTree(), &bytes.Buffer{}); !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	return nil
+}
+
+// checkCanceledDecompress decompresses with a canceled context
+func checkCanceledDecompress(c compressor.Compressor) error {
+	data, err := compressed(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := c.Decompress(ctx, bytes.NewReader(data))
+	if !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	if result != nil {
+		return errors.New("expected no directory along with the error")
+	}
+
+	return nil
+}
+
+// checkTruncated decompresses prefixes of the compressed sample tree. Compressors that decompress lazily may
+// report the error when the content is read.
+func checkTruncated(c compressor.Compressor) error {
+	data, err := compressed(c)
+	if err != nil {
+		return err
+	}
+
+	for _, length := range []int{0, 1, len(data) / 2, len(data) - 1} {
+		if err := decompressFully(c, data[:length]); err == nil {
+			return fmt.Errorf("decompressing the first %d of %d bytes did not fail", length, len(data))
+		}
+	}
+
+	return nil
+}
+
+// checkGarbage decompresses pseudo random bytes
+func checkGarbage(c compressor.Compressor) error {
+	random := rand.New(rand.NewSource(2))
+	for _, length := range []int{16, 512, 64 * 1024} {
+		data := make([]byte, length)
+		_, _ = random.Read(data)
+
+		if err := decompressFully(c, data); err == nil {
+			return fmt.Errorf("decompressing %d random bytes did not fail", length)
+		}
+	}
+
+	return nil
+}
+
+// malformedNames are entry names that cannot be created through files.Directory. Names ending with a slash are
+// written as directory entries, all others as file entries.
+var malformedNames = []string{"", "../x", "/", "./", "../x/"}
+
+// checkMalformedNames decompresses archives with an entry of each malformed name, which has to fail. Directory
+// entries of the root, i.e. "/" and "./", may be ignored instead. The archives are written based on the file
+// extension of the compressor, pipelines encode them with their codec. Compressors whose format is not known to
+// the suite pass unchecked.
+func checkMalformedNames(c compressor.Compressor) error {
+	write := archiveWriter(c)
+	if write == nil {
+		return nil
+	}
+
+	for _, name := range malformedNames {
+		data, err := write(name)
+		if err != nil {
+			return err
+		}
+
+		result, err := c.Decompress(context.Background(), bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+
+		if name != "/" && name != "./" {
+			return fmt.Errorf("decompressing an entry named %q did not fail", name)
+		}
+
+		if found, err := entries(result); err != nil || len(found) > 0 {
+			return fmt.Errorf("decompressing a root entry named %q created the entries %v, %v", name, found, err)
+		}
+	}
+
+	return nil
+}
+
+// archiveWriter returns a function that writes an archive in the format of the compressor with one file entry of
+// the given name, or nil if the format is unknown
+func archiveWriter(c compressor.Compressor) func(name string) ([]byte, error) {
+	if pipeline, ok := c.(*compressor.Pipeline); ok {
+		write := archiveWriter(pipeline.Archiver)
+		if write == nil {
+			return nil
+		}
+
+		return func(name string) ([]byte, error) {
+			archive, err := write(name)
+			if err != nil {
+				return nil, err
+			}
+			return encode(archive, pipeline.Codec.Writer)
+		}
+	}
+
+	switch compressor.MetadataOf(c).Extension {
+	case ".zip":
+		return zipArchive
+
+	case ".tar":
+		return tarArchive
+
+	case ".tar.gz", ".tgz":
+		return func(name string) ([]byte, error) {
+			archive, err := tarArchive(name)
+			if err != nil {
+				return nil, err
+			}
+			return encode(archive, func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil })
+		}
+	}
+
+	return nil
+}
+
+// tarArchive writes a tar archive with one entry of the name, which is a directory if the name ends with a slash
+func tarArchive(name string) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(buffer)
+	if strings.HasSuffix(name, "/") {
+		if err := tarWriter.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0755}); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := tarWriter.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: 1}); err != nil {
+			return nil, err
+		}
+
+		if _, err := tarWriter.Write([]byte("x")); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// zipArchive writes a zip archive with one entry of the name, which is a directory if the name ends with a slash
+func zipArchive(name string) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(buffer)
+	entryWriter, err := zipWriter.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(name, "/") {
+		if _, err := entryWriter.Write([]byte("x")); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// encode writes the data through the writer created by newWriter
+func encode(data []byte, newWriter func(w io.Writer) (io.WriteCloser, error)) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	writer, err := newWriter(buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decompressFully decompresses the data and reads the content of all files
+func decompressFully(c compressor.Compressor, data []byte) error {
+	result, err := c.Decompress(context.Background(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return errors.New("decompressed directory is nil")
+	}
+
+	_, err = entries(result)
+	return err
+}
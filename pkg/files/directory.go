@@ -77,6 +77,7 @@ type memoryDirectory struct {
 	files    []File
 	dirs     []Directory
 	PermBits os.FileMode
+	lookup   LookupMode
 }
 
 // Name returns the name of the directory
@@ -116,16 +117,16 @@ func (m *memoryDirectory) File(path paths.Path) (file File) {
 
 	if path.Direct() {
 		for _, file := range m.Files() {
-			if file.Name().Equals(path) {
+			if m.lookup.Equals(file.Name(), path) {
 				return file
 			}
 		}
 	} else {
-		newPath := path.Clone()
+		first, rest := path.Pop()
 
-		subDirectory := m.Directory(newPath.Pop())
+		subDirectory := m.Directory(first)
 		if subDirectory != nil {
-			return subDirectory.File(newPath)
+			return subDirectory.File(rest)
 		}
 	}
 
@@ -138,19 +139,18 @@ func (m *memoryDirectory) NewFile(path paths.Path) (newFile File) {
 		return nil
 	}
 
-	newPath := path.Clone()
 	if !path.Direct() {
-		fileName := newPath.Drop()
-		return m.NewDirectory(newPath).NewFile(fileName)
+		parentPath, fileName := path.Drop()
+		return m.NewDirectory(parentPath).NewFile(fileName)
 	}
 
-	if foundFile := m.File(newPath); foundFile != nil { // return existing file
+	if foundFile := m.File(path); foundFile != nil { // return existing file
 		return foundFile
 	}
 
 	file := &memoryFile{
 		parent: m,
-		name:   newPath,
+		name:   path,
 	}
 	file.WithPermission(m.PermissionSet())
 	m.files = append(m.files, file)
@@ -164,9 +164,8 @@ func (m *memoryDirectory) DeleteFile(path paths.Path) {
 	}
 
 	if !path.Direct() {
-		newPath := path.Clone()
-		fileName := newPath.Drop()
-		parentDirectory := m.Directory(newPath)
+		parentPath, fileName := path.Drop()
+		parentDirectory := m.Directory(parentPath)
 		if parentDirectory != nil {
 			parentDirectory.DeleteFile(fileName)
 		}
@@ -174,7 +173,7 @@ func (m *memoryDirectory) DeleteFile(path paths.Path) {
 	}
 
 	for index, file := range m.Files() {
-		if file.Name().Equals(path) {
+		if m.lookup.Equals(file.Name(), path) {
 			m.files = append(m.files[:index], m.files[index+1:]...)
 		}
 	}
@@ -191,18 +190,18 @@ func (m *memoryDirectory) Directory(path paths.Path) (directory Directory) {
 		return nil
 	}
 
-	newPath := path.Clone()
 	if !path.Direct() {
-		firstDirectory := m.Directory(newPath.Pop())
+		first, rest := path.Pop()
+		firstDirectory := m.Directory(first)
 		if firstDirectory == nil {
 			return nil
 		}
 
-		return firstDirectory.Directory(newPath)
+		return firstDirectory.Directory(rest)
 	}
 
 	for _, dir := range m.Directories() {
-		if dir.Name().Equals(path) {
+		if m.lookup.Equals(dir.Name(), path) {
 			return dir
 		}
 	}
@@ -217,17 +216,16 @@ func (m *memoryDirectory) NewDirectory(path paths.Path) (newDirectory Directory)
 	}
 
 	if !path.Direct() {
-		newPath := path.Clone()
-		thisLevelDirectory := newPath.Pop()
+		thisLevelDirectory, rest := path.Pop()
 
 		foundDirectory := m.Directory(thisLevelDirectory)
 		if foundDirectory != nil {
-			return foundDirectory.NewDirectory(newPath)
+			return foundDirectory.NewDirectory(rest)
 		}
 
 		newLevelDirectory := m.NewDirectory(thisLevelDirectory)
 		if newLevelDirectory != nil {
-			return newLevelDirectory.NewDirectory(newPath)
+			return newLevelDirectory.NewDirectory(rest)
 		}
 	}
 
@@ -238,6 +236,7 @@ func (m *memoryDirectory) NewDirectory(path paths.Path) (newDirectory Directory)
 	createdDirectory := &memoryDirectory{
 		name:   path,
 		parent: m,
+		lookup: m.lookup,
 	}
 	createdDirectory.WithPermission(m.PermissionSet())
 
@@ -252,9 +251,8 @@ func (m *memoryDirectory) DeleteDirectory(path paths.Path) {
 	}
 
 	if !path.Direct() {
-		newPath := path.Clone()
-		directoryName := newPath.Drop()
-		parentDirectory := m.Directory(newPath)
+		parentPath, directoryName := path.Drop()
+		parentDirectory := m.Directory(parentPath)
 		if parentDirectory != nil {
 			parentDirectory.DeleteDirectory(directoryName)
 		}
@@ -262,7 +260,7 @@ func (m *memoryDirectory) DeleteDirectory(path paths.Path) {
 	}
 
 	for index, directory := range m.Directories() {
-		if directory.Name().Equals(path) {
+		if m.lookup.Equals(directory.Name(), path) {
 			m.dirs = append(m.dirs[:index], m.dirs[index+1:]...)
 		}
 	}
@@ -273,9 +271,14 @@ func (m *memoryDirectory) Parent() (parentDirectory Directory) {
 	return m.parent
 }
 
+// LookupMode returns the mode used to compare names in this directory
+func (m *memoryDirectory) LookupMode() LookupMode {
+	return m.lookup
+}
+
 // AsRoot creates a deep copy of the current directory, but with the current directory as it's root
 func (m *memoryDirectory) AsRoot() (rootDirectory Directory) {
-	root := NewRootDirectory()
+	root := NewRootDirectoryWithLookupMode(m.lookup)
 	if err := copyDirectory(m, root); err != nil {
 		return nil
 	}
@@ -305,8 +308,15 @@ func copyDirectory(original Directory, new Directory) error {
 
 // NewRootDirectory returns a new root directory
 func NewRootDirectory() Directory {
+	return NewRootDirectoryWithLookupMode(ExactLookup)
+}
+
+// NewRootDirectoryWithLookupMode returns a new root directory that compares names using the lookup mode.
+// Directories created below the root inherit its lookup mode.
+func NewRootDirectoryWithLookupMode(mode LookupMode) Directory {
 	return &memoryDirectory{
 		name:     paths.RootPath(),
 		PermBits: 0777,
+		lookup:   mode,
 	}
 }
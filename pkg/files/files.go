@@ -23,9 +23,12 @@
 package files
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"sync"
 
 	"github.com/homeport/pina-golada/pkg/files/paths"
 )
@@ -54,12 +57,60 @@ type File interface {
 	Parent() (parentDirectory Directory)
 }
 
+// ContentLoader provides the content of a lazy file when it is accessed for the first time
+type ContentLoader func() (content []byte, e error)
+
 // memoryFile is an in memory implementation of the File interface
 type memoryFile struct {
 	name     paths.Path
 	parent   Directory
 	content  []byte
 	PermBits os.FileMode
+
+	loader   ContentLoader
+	loadLock sync.Mutex
+}
+
+// NewLazyFile creates a file at the path below the directory whose content is provided by the loader once it is
+// accessed for the first time. The loader is called at most once, unless it fails. Files of directories that
+// are not kept in memory are loaded right away.
+func NewLazyFile(directory Directory, path paths.Path, loader ContentLoader) (File, error) {
+	file := directory.NewFile(path)
+	if file == nil {
+		return nil, fmt.Errorf("could not create file %s", path.String())
+	}
+
+	if m, ok := file.(*memoryFile); ok {
+		m.loadLock.Lock()
+		m.content, m.loader = nil, loader
+		m.loadLock.Unlock()
+		return m, nil
+	}
+
+	content, e := loader()
+	if e != nil {
+		return nil, e
+	}
+
+	return file, file.Write(bytes.NewReader(content))
+}
+
+// load calls the loader of a lazy file if it was not loaded yet
+func (m *memoryFile) load() error {
+	m.loadLock.Lock()
+	defer m.loadLock.Unlock()
+
+	if m.loader == nil {
+		return nil
+	}
+
+	content, e := m.loader()
+	if e != nil {
+		return fmt.Errorf("could not load content of %s: %w", m.AbsolutePath().String(), e)
+	}
+
+	m.content, m.loader = content, nil
+	return nil
 }
 
 // Name Returns the name of the file
@@ -74,10 +125,22 @@ func (m *memoryFile) AbsolutePath() (path paths.Path) {
 
 // CopyContent copies the content of the writer
 func (m *memoryFile) CopyContent(writer io.Writer) (e error) {
+	if e = m.load(); e != nil {
+		return e
+	}
+
 	_, e = writer.Write(m.content)
 	return e
 }
 
+// Size returns the amount of bytes of the content, which loads the content of a lazy file
+func (m *memoryFile) Size() (int64, error) {
+	if e := m.load(); e != nil {
+		return 0, e
+	}
+	return int64(len(m.content)), nil
+}
+
 // Write writes the content of the reader
 func (m *memoryFile) Write(reader io.Reader) (e error) {
 	return m.WriteFlagged(reader, false)
@@ -85,19 +148,38 @@ func (m *memoryFile) Write(reader io.Reader) (e error) {
 
 // WriteFlagged writes the content of the reader to the file and appends it if appendBytes is true
 func (m *memoryFile) WriteFlagged(reader io.Reader, appendBytes bool) (e error) {
-	bytes, e := ioutil.ReadAll(reader)
+	bytes, e := readAll(reader)
 	if e != nil {
 		return e
 	}
 
 	if appendBytes {
+		if e = m.load(); e != nil {
+			return e
+		}
+
 		m.content = append(m.content, bytes...)
 	} else {
-		m.content = bytes
+		m.loadLock.Lock()
+		m.content, m.loader = bytes, nil
+		m.loadLock.Unlock()
 	}
 	return nil
 }
 
+// readAll reads the reader until its end. Readers that know their remaining length, like bytes.Reader, are read
+// into a buffer that is allocated once.
+func readAll(reader io.Reader) ([]byte, error) {
+	sized, ok := reader.(interface{ Len() int })
+	if !ok {
+		return ioutil.ReadAll(reader)
+	}
+
+	buffer := bytes.NewBuffer(make([]byte, 0, sized.Len()+bytes.MinRead))
+	_, e := buffer.ReadFrom(reader)
+	return buffer.Bytes(), e
+}
+
 // Delete deletes the file
 func (m *memoryFile) Delete() {
 	m.Parent().DeleteFile(m.Name())
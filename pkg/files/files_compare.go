@@ -0,0 +1,357 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package files
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+// DifferenceType classifies how an entry on the host file system deviates from the Directory it was compared with
+type DifferenceType int
+
+const (
+	// Unchanged marks an entry that matches the directory in content and permission
+	Unchanged DifferenceType = iota
+
+	// Modified marks an entry whose content or type differs from the directory
+	Modified
+
+	// Missing marks an entry of the directory that does not exist on the host file system
+	Missing
+
+	// Extra marks an entry on the host file system that is not part of the directory
+	Extra
+
+	// PermissionChanged marks an entry whose content matches but whose permission set differs
+	PermissionChanged
+)
+
+// String returns the human readable name of the difference type
+func (t DifferenceType) String() string {
+	switch t {
+	case Unchanged:
+		return "unchanged"
+	case Modified:
+		return "modified"
+	case Missing:
+		return "missing"
+	case Extra:
+		return "extra"
+	case PermissionChanged:
+		return "permission changed"
+	}
+	return "unknown"
+}
+
+// Difference describes the state of a single entry found while comparing a directory with the host file system
+//
+// Path is relative to the path the directory was compared against.
+//
+// Expected and Actual hold the permission set of the directory entry and the host entry. Both are zero if the
+// respective side does not exist.
+type Difference struct {
+	Path        paths.Path
+	Type        DifferenceType
+	IsDirectory bool
+	Expected    os.FileMode
+	Actual      os.FileMode
+}
+
+// Differences is a list of differences as returned by CompareWithDisk
+type Differences []Difference
+
+// Filter returns all differences that are of one of the given types
+func (d Differences) Filter(types ...DifferenceType) Differences {
+	var result Differences
+	for _, difference := range d {
+		for _, t := range types {
+			if difference.Type == t {
+				result = append(result, difference)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Changed returns all differences that are not Unchanged. These are the entries a restore would have to touch
+func (d Differences) Changed() Differences {
+	return d.Filter(Modified, Missing, Extra, PermissionChanged)
+}
+
+// CompareWithDisk compares the directory with the content found under the given path on the host file system.
+// The path is resolved the same way WriteToDisk resolves it, so comparing right after writing reports every entry
+// as Unchanged. Sizes and permissions are checked first, the content is only hashed if the sizes match.
+func CompareWithDisk(directory Directory, path string) (differences Differences, e error) {
+	relative := ""
+	if directory.Parent() != nil { // Non root directories are compared against a directory of the same name
+		path = filepath.Join(path, directory.Name().String())
+		relative = directory.Name().String()
+	}
+
+	info, e := os.Lstat(path)
+	if e != nil {
+		if !os.IsNotExist(e) {
+			return nil, e
+		}
+
+		return appendMissing(differences, directory, relative), nil
+	}
+
+	if !info.IsDir() {
+		return append(differences, Difference{
+			Path:        paths.Of(relative),
+			Type:        Modified,
+			IsDirectory: true,
+			Expected:    directory.PermissionSet(),
+			Actual:      info.Mode(),
+		}), nil
+	}
+
+	return compareDirectory(differences, directory, relative, path, info, directory.Parent() == nil)
+}
+
+// compareDirectory compares the directory and everything below it with the host directory at the path
+func compareDirectory(differences Differences, directory Directory, relative string, path string,
+	info os.FileInfo, root bool) (Differences, error) {
+	if !root {
+		differences = append(differences, Difference{
+			Path:        paths.Of(relative),
+			Type:        permissionDifference(directory.PermissionSet(), info.Mode()),
+			IsDirectory: true,
+			Expected:    directory.PermissionSet(),
+			Actual:      info.Mode(),
+		})
+	}
+
+	known := make(map[string]bool)
+	for _, dir := range directory.Directories() {
+		known[dir.Name().String()] = true
+
+		dirPath := filepath.Join(path, dir.Name().String())
+		dirRelative := joinRelative(relative, dir.Name())
+
+		dirInfo, e := os.Lstat(dirPath)
+		if e != nil {
+			if !os.IsNotExist(e) {
+				return nil, e
+			}
+
+			differences = appendMissing(differences, dir, dirRelative)
+			continue
+		}
+
+		if !dirInfo.IsDir() {
+			differences = append(differences, Difference{
+				Path:        paths.Of(dirRelative),
+				Type:        Modified,
+				IsDirectory: true,
+				Expected:    dir.PermissionSet(),
+				Actual:      dirInfo.Mode(),
+			})
+			continue
+		}
+
+		nested, e := compareDirectory(differences, dir, dirRelative, dirPath, dirInfo, false)
+		if e != nil {
+			return nil, e
+		}
+		differences = nested
+	}
+
+	for _, file := range directory.Files() {
+		known[file.Name().String()] = true
+
+		difference, e := compareFile(file, joinRelative(relative, file.Name()), filepath.Join(path, file.Name().String()))
+		if e != nil {
+			return nil, e
+		}
+		differences = append(differences, difference)
+	}
+
+	content, e := ioutil.ReadDir(path)
+	if e != nil {
+		return nil, e
+	}
+
+	for _, entry := range content {
+		if known[entry.Name()] {
+			continue
+		}
+
+		differences = append(differences, Difference{
+			Path:        paths.Of(joinRelative(relative, paths.Of(entry.Name()))),
+			Type:        Extra,
+			IsDirectory: entry.IsDir(),
+			Actual:      entry.Mode(),
+		})
+	}
+
+	return differences, nil
+}
+
+// compareFile compares a single file with the host file at the path
+func compareFile(file File, relative string, path string) (Difference, error) {
+	difference := Difference{
+		Path:     paths.Of(relative),
+		Expected: file.PermissionSet(),
+	}
+
+	info, e := os.Lstat(path)
+	if e != nil {
+		if !os.IsNotExist(e) {
+			return difference, e
+		}
+
+		difference.Type = Missing
+		return difference, nil
+	}
+
+	difference.Actual = info.Mode()
+	if !info.Mode().IsRegular() {
+		difference.Type = Modified
+		return difference, nil
+	}
+
+	size, e := fileSize(file)
+	if e != nil {
+		return difference, e
+	}
+
+	if size != info.Size() {
+		difference.Type = Modified
+		return difference, nil
+	}
+
+	equal, e := equalContent(file, path)
+	if e != nil {
+		return difference, e
+	}
+
+	if !equal {
+		difference.Type = Modified
+		return difference, nil
+	}
+
+	difference.Type = permissionDifference(file.PermissionSet(), info.Mode())
+	return difference, nil
+}
+
+// appendMissing marks the directory and everything found below it as missing
+func appendMissing(differences Differences, directory Directory, relative string) Differences {
+	if directory.Parent() != nil {
+		differences = append(differences, Difference{
+			Path:        paths.Of(relative),
+			Type:        Missing,
+			IsDirectory: true,
+			Expected:    directory.PermissionSet(),
+		})
+	}
+
+	for _, dir := range directory.Directories() {
+		differences = appendMissing(differences, dir, joinRelative(relative, dir.Name()))
+	}
+
+	for _, file := range directory.Files() {
+		differences = append(differences, Difference{
+			Path:     paths.Of(joinRelative(relative, file.Name())),
+			Type:     Missing,
+			Expected: file.PermissionSet(),
+		})
+	}
+
+	return differences
+}
+
+// joinRelative appends the name to the slash separated relative path
+func joinRelative(relative string, name paths.Path) string {
+	if len(relative) == 0 {
+		return name.String()
+	}
+	return relative + paths.Separator + name.String()
+}
+
+// permissionDifference returns PermissionChanged if the permission bits differ. Windows does not provide
+// meaningful permission bits, which is why they are never reported as changed there
+func permissionDifference(expected os.FileMode, actual os.FileMode) DifferenceType {
+	if runtime.GOOS != "windows" && expected.Perm() != actual.Perm() {
+		return PermissionChanged
+	}
+	return Unchanged
+}
+
+// sizer is implemented by files that know their size without copying their content, like in memory files
+type sizer interface {
+	Size() (int64, error)
+}
+
+// fileSize returns the amount of bytes stored in the file
+func fileSize(file File) (int64, error) {
+	if sized, ok := file.(sizer); ok {
+		return sized.Size()
+	}
+
+	counter := &countingWriter{}
+	if err := file.CopyContent(counter); err != nil {
+		return 0, err
+	}
+	return counter.count, nil
+}
+
+// equalContent compares the checksum of the file with the checksum of the host file at the path
+func equalContent(file File, path string) (bool, error) {
+	expected := sha256.New()
+	if err := file.CopyContent(expected); err != nil {
+		return false, err
+	}
+
+	hostFile, e := os.Open(path)
+	if e != nil {
+		return false, e
+	}
+	defer hostFile.Close()
+
+	actual := sha256.New()
+	if _, err := io.Copy(actual, hostFile); err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(expected.Sum(nil), actual.Sum(nil)), nil
+}
+
+// countingWriter is a writer that only counts the bytes written to it
+type countingWriter struct {
+	count int64
+}
+
+// Write counts the length of the slice
+func (c *countingWriter) Write(p []byte) (n int, err error) {
+	c.count += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,127 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package files
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should compare directories with the disk", func() {
+
+	var (
+		root      Directory
+		targetDir string
+	)
+
+	BeforeEach(func() {
+		root = NewRootDirectory()
+		Expect(root.NewDirectory(paths.Of("config")).WithPermission(0755)).To(Not(BeNil()))
+		Expect(root.NewFile(paths.Of("config/app.yml")).WithPermission(0644).Write(bytes.NewBufferString("port: 8080"))).To(BeNil())
+		Expect(root.NewFile(paths.Of("README.md")).WithPermission(0644).Write(bytes.NewBufferString("readme"))).To(BeNil())
+
+		var e error
+		targetDir, e = ioutil.TempDir("", "pgl-compare")
+		Expect(e).To(BeNil())
+		Expect(WriteToDisk(root, targetDir, true)).To(BeNil())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(targetDir)).To(BeNil())
+	})
+
+	typeOf := func(differences Differences, path string) DifferenceType {
+		for _, difference := range differences {
+			if difference.Path.Equals(paths.Of(path)) {
+				return difference.Type
+			}
+		}
+		Fail("no difference reported for " + path)
+		return Unchanged
+	}
+
+	_ = It("should report a freshly written tree as unchanged", func() {
+		differences, e := CompareWithDisk(root, targetDir)
+		Expect(e).To(BeNil())
+		Expect(len(differences)).To(BeEquivalentTo(3))
+		Expect(differences.Changed()).To(BeEmpty())
+	})
+
+	_ = It("should detect modified files", func() {
+		Expect(ioutil.WriteFile(filepath.Join(targetDir, "README.md"), []byte("edited"), 0644)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(targetDir, "config", "app.yml"), []byte("port: 9090 # edited"), 0644)).To(BeNil())
+
+		differences, e := CompareWithDisk(root, targetDir)
+		Expect(e).To(BeNil())
+		Expect(typeOf(differences, "README.md")).To(BeEquivalentTo(Modified))
+		Expect(typeOf(differences, "config/app.yml")).To(BeEquivalentTo(Modified))
+	})
+
+	_ = It("should detect missing and extra entries", func() {
+		Expect(os.Remove(filepath.Join(targetDir, "README.md"))).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(targetDir, "config", "local.yml"), []byte("debug: true"), 0644)).To(BeNil())
+
+		differences, e := CompareWithDisk(root, targetDir)
+		Expect(e).To(BeNil())
+		Expect(typeOf(differences, "README.md")).To(BeEquivalentTo(Missing))
+		Expect(typeOf(differences, "config/local.yml")).To(BeEquivalentTo(Extra))
+		Expect(len(differences.Changed())).To(BeEquivalentTo(2))
+	})
+
+	_ = It("should report everything below a missing directory as missing", func() {
+		Expect(os.RemoveAll(filepath.Join(targetDir, "config"))).To(BeNil())
+
+		differences, e := CompareWithDisk(root, targetDir)
+		Expect(e).To(BeNil())
+		Expect(typeOf(differences, "config")).To(BeEquivalentTo(Missing))
+		Expect(typeOf(differences, "config/app.yml")).To(BeEquivalentTo(Missing))
+	})
+
+	_ = It("should detect changed permissions", func() {
+		if IsOS("windows") {
+			Skip("Skipped on windows")
+			return
+		}
+
+		Expect(os.Chmod(filepath.Join(targetDir, "README.md"), 0600)).To(BeNil())
+		Expect(os.Chmod(filepath.Join(targetDir, "config"), 0700)).To(BeNil())
+
+		differences, e := CompareWithDisk(root, targetDir)
+		Expect(e).To(BeNil())
+		Expect(typeOf(differences, "README.md")).To(BeEquivalentTo(PermissionChanged))
+		Expect(typeOf(differences, "config")).To(BeEquivalentTo(PermissionChanged))
+		Expect(typeOf(differences, "config/app.yml")).To(BeEquivalentTo(Unchanged))
+	})
+
+	_ = It("should compare non root directories against a directory of the same name", func() {
+		differences, e := CompareWithDisk(root.Directory(paths.Of("config")), targetDir)
+		Expect(e).To(BeNil())
+		Expect(typeOf(differences, "config")).To(BeEquivalentTo(Unchanged))
+		Expect(typeOf(differences, "config/app.yml")).To(BeEquivalentTo(Unchanged))
+	})
+})
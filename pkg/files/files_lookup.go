@@ -0,0 +1,215 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+// LookupMode defines how a directory compares names when looking up its files and directories.
+// The modes can be combined, e.g. CaseInsensitiveLookup | NormalizedLookup.
+type LookupMode int
+
+const (
+	// ExactLookup compares names byte by byte
+	ExactLookup LookupMode = 0
+
+	// CaseInsensitiveLookup compares names using unicode case folding, like the default file systems of
+	// macOS and windows do
+	CaseInsensitiveLookup LookupMode = 1 << 0
+
+	// NormalizedLookup compares names after unicode NFC normalization, so decomposed (NFD) names as
+	// created on macOS match their composed counterparts
+	NormalizedLookup LookupMode = 1 << 1
+)
+
+// lookupModer is implemented by directories that compare names using a lookup mode, like the in memory directory
+type lookupModer interface {
+	LookupMode() LookupMode
+}
+
+// LookupModeOf returns the lookup mode of the directory, or ExactLookup if the directory does not define one
+func LookupModeOf(directory Directory) LookupMode {
+	if moder, ok := directory.(lookupModer); ok {
+		return moder.LookupMode()
+	}
+	return ExactLookup
+}
+
+// ParseLookupMode parses the human readable name of a lookup mode as returned by LookupMode.String, e.g.
+// "case-insensitive+normalized". The mode "insensitive" is accepted as short form of "case-insensitive".
+func ParseLookupMode(name string) (LookupMode, error) {
+	mode := ExactLookup
+	for _, element := range strings.Split(strings.ToLower(name), "+") {
+		switch strings.TrimSpace(element) {
+		case "exact":
+
+		case "case-insensitive", "insensitive":
+			mode |= CaseInsensitiveLookup
+
+		case "normalized":
+			mode |= NormalizedLookup
+
+		default:
+			return ExactLookup, fmt.Errorf("unknown lookup mode %q, expected exact, case-insensitive or normalized", name)
+		}
+	}
+
+	return mode, nil
+}
+
+// Key returns the representation of the name that is compared under the lookup mode
+func (l LookupMode) Key(name string) string {
+	if l&NormalizedLookup != 0 {
+		name = norm.NFC.String(name)
+	}
+
+	if l&CaseInsensitiveLookup != 0 {
+		name = cases.Fold().String(name)
+	}
+
+	return name
+}
+
+// Equals returns if both paths are equal under the lookup mode
+func (l LookupMode) Equals(a paths.Path, b paths.Path) bool {
+	if l == ExactLookup {
+		return a.Equals(b)
+	}
+
+	if a.Size() != b.Size() {
+		return false
+	}
+
+	other := b.Slice()
+	for index, element := range a.Slice() {
+		if l.Key(element) != l.Key(other[index]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the human readable name of the lookup mode
+func (l LookupMode) String() string {
+	if l == ExactLookup {
+		return "exact"
+	}
+
+	var modes []string
+	if l&CaseInsensitiveLookup != 0 {
+		modes = append(modes, "case-insensitive")
+	}
+	if l&NormalizedLookup != 0 {
+		modes = append(modes, "normalized")
+	}
+	return strings.Join(modes, "+")
+}
+
+// CollisionError is returned when two entries of the same directory map to the same name under the lookup mode
+// of the directory
+type CollisionError struct {
+	Directory paths.Path
+	Existing  string
+	Name      string
+	Mode      LookupMode
+}
+
+// Error returns the description of the collision
+func (c *CollisionError) Error() string {
+	return fmt.Sprintf("%q collides with %q in directory %q under %s lookup", c.Name, c.Existing,
+		c.Directory.String(), c.Mode.String())
+}
+
+// checkCollision returns a CollisionError if the directory already hosts an entry that has the same name as the
+// given one under the lookup mode of the directory
+func checkCollision(directory Directory, name string) error {
+	mode := LookupModeOf(directory)
+	if mode == ExactLookup {
+		return nil
+	}
+
+	path := paths.Of(name)
+	for _, file := range directory.Files() {
+		if mode.Equals(file.Name(), path) {
+			return &CollisionError{Directory: directory.AbsolutePath(), Existing: file.Name().String(), Name: name, Mode: mode}
+		}
+	}
+
+	for _, dir := range directory.Directories() {
+		if mode.Equals(dir.Name(), path) {
+			return &CollisionError{Directory: directory.AbsolutePath(), Existing: dir.Name().String(), Name: name, Mode: mode}
+		}
+	}
+
+	return nil
+}
+
+// WithLookupMode creates a deep copy of the directory as a root directory that uses the lookup mode. A
+// CollisionError is returned if two entries of the directory map to the same name under the lookup mode.
+func WithLookupMode(directory Directory, mode LookupMode) (Directory, error) {
+	root := NewRootDirectoryWithLookupMode(mode)
+	root.WithPermission(directory.PermissionSet())
+
+	if err := copyDirectoryChecked(directory, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// copyDirectoryChecked copies the content and permissions of one directory into the other while checking
+// each entry for collisions under the lookup mode of the target
+func copyDirectoryChecked(original Directory, target Directory) error {
+	for _, dir := range original.Directories() {
+		if err := checkCollision(target, dir.Name().String()); err != nil {
+			return err
+		}
+
+		if err := copyDirectoryChecked(dir, target.NewDirectory(dir.Name()).WithPermission(dir.PermissionSet())); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range original.Files() {
+		if err := checkCollision(target, f.Name().String()); err != nil {
+			return err
+		}
+
+		content := &bytes.Buffer{}
+		if err := f.CopyContent(content); err != nil {
+			return err
+		}
+
+		if err := target.NewFile(f.Name()).WithPermission(f.PermissionSet()).Write(content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
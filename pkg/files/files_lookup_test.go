@@ -0,0 +1,150 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package files
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+// directoryMethods are the methods of the Directory interface
+type directoryMethods interface{ Directory }
+
+// plainDirectory only exposes the methods of the Directory interface of the directory it embeds
+type plainDirectory struct{ directoryMethods }
+
+var _ = Describe("should look up entries using lookup modes", func() {
+
+	const (
+		composed   = "caf\u00e9.txt"  // NFC, as created on linux
+		decomposed = "cafe\u0301.txt" // NFD, as created on macOS
+	)
+
+	var tempDir string
+
+	BeforeEach(func() {
+		var e error
+		tempDir, e = ioutil.TempDir("", "pgl-lookup")
+		Expect(e).To(BeNil())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(BeNil())
+	})
+
+	_ = It("should only find exact matches by default", func() {
+		root := NewRootDirectory()
+		root.NewFile(paths.Of("docs/README.md"))
+
+		Expect(LookupModeOf(root)).To(BeEquivalentTo(ExactLookup))
+		Expect(root.File(paths.Of("docs/README.md"))).To(Not(BeNil()))
+		Expect(root.File(paths.Of("Docs/readme.md"))).To(BeNil())
+	})
+
+	_ = It("should find entries case insensitive", func() {
+		root := NewRootDirectoryWithLookupMode(CaseInsensitiveLookup)
+		root.NewFile(paths.Of("docs/README.md"))
+
+		Expect(root.Directory(paths.Of("DOCS"))).To(Not(BeNil()))
+		Expect(root.File(paths.Of("Docs/readme.md"))).To(Not(BeNil()))
+		Expect(LookupModeOf(root.Directory(paths.Of("docs")))).To(BeEquivalentTo(CaseInsensitiveLookup))
+
+		root.NewFile(paths.Of("DOCS/readme.MD"))
+		Expect(len(root.Directory(paths.Of("docs")).Files())).To(BeEquivalentTo(1))
+	})
+
+	_ = It("should treat directories without a lookup mode as exact", func() {
+		wrapped := plainDirectory{NewRootDirectoryWithLookupMode(CaseInsensitiveLookup)}
+		Expect(LookupModeOf(wrapped)).To(BeEquivalentTo(ExactLookup))
+		Expect(checkCollision(wrapped, "anything")).To(BeNil())
+	})
+
+	_ = It("should find decomposed names using their composed form", func() {
+		Expect(ioutil.WriteFile(filepath.Join(tempDir, decomposed), []byte("menu"), 0644)).To(BeNil())
+
+		root := NewRootDirectoryWithLookupMode(NormalizedLookup)
+		Expect(LoadFromDisk(root, tempDir)).To(BeNil())
+
+		file := root.File(paths.Of(composed))
+		Expect(file).To(Not(BeNil()))
+		Expect(file.Name().String()).To(BeEquivalentTo(decomposed))
+
+		Expect(NewRootDirectory().File(paths.Of(composed))).To(BeNil())
+	})
+
+	_ = It("should detect entries colliding under the lookup mode when loading from disk", func() {
+		Expect(ioutil.WriteFile(filepath.Join(tempDir, composed), []byte("a"), 0644)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(tempDir, decomposed), []byte("b"), 0644)).To(BeNil())
+
+		Expect(LoadFromDisk(NewRootDirectory(), tempDir)).To(BeNil())
+
+		e := LoadFromDisk(NewRootDirectoryWithLookupMode(NormalizedLookup), tempDir)
+		Expect(e).To(HaveOccurred())
+		Expect(e).To(BeAssignableToTypeOf(&CollisionError{}))
+	})
+
+	_ = It("should detect files and directories colliding case insensitive", func() {
+		Expect(os.Mkdir(filepath.Join(tempDir, "Assets"), 0755)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(tempDir, "assets"), []byte("a"), 0644)).To(BeNil())
+
+		e := LoadFromDisk(NewRootDirectoryWithLookupMode(CaseInsensitiveLookup), tempDir)
+		Expect(e).To(BeAssignableToTypeOf(&CollisionError{}))
+	})
+
+	_ = It("should copy directories into a new lookup mode", func() {
+		root := NewRootDirectory()
+		Expect(root.NewFile(paths.Of("docs/README.md")).WithPermission(0600).Write(bytes.NewBufferString("readme"))).To(BeNil())
+
+		copied, e := WithLookupMode(root, CaseInsensitiveLookup)
+		Expect(e).To(BeNil())
+
+		file := copied.File(paths.Of("docs/readme.md"))
+		Expect(file).To(Not(BeNil()))
+		Expect(file.PermissionSet()).To(BeEquivalentTo(0600))
+
+		root.NewFile(paths.Of("docs/readme.md"))
+		_, e = WithLookupMode(root, CaseInsensitiveLookup)
+		Expect(e).To(BeAssignableToTypeOf(&CollisionError{}))
+	})
+
+	_ = It("should parse the names of the lookup modes", func() {
+		Expect(CaseInsensitiveLookup).To(BeEquivalentTo(1))
+		Expect(NormalizedLookup).To(BeEquivalentTo(2))
+
+		for _, mode := range []LookupMode{ExactLookup, CaseInsensitiveLookup, NormalizedLookup, CaseInsensitiveLookup | NormalizedLookup} {
+			parsed, e := ParseLookupMode(mode.String())
+			Expect(e).To(BeNil())
+			Expect(parsed).To(Equal(mode))
+		}
+
+		Expect(ParseLookupMode("Insensitive")).To(Equal(CaseInsensitiveLookup))
+
+		_, e := ParseLookupMode("fuzzy")
+		Expect(e).ToNot(BeNil())
+	})
+})
@@ -0,0 +1,81 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package files
+
+import (
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+// ValidatePortable checks all names of the directory and its sub directories against the file name rules of the
+// platforms. Every invalid name is reported as a paths.NameError and every pair of names that collide on a case
+// insensitive platform is reported as a CollisionError. The returned list is empty if the directory is portable.
+func ValidatePortable(directory Directory, platforms paths.Platform) []error {
+	var result []error
+	mode := portableLookupMode(platforms)
+	names := map[string]string{}
+
+	check := func(path paths.Path) {
+		name := path.Base()
+		if e := paths.ValidateName(name, platforms); e != nil {
+			nameError := e.(*paths.NameError)
+			nameError.Path = path
+			result = append(result, nameError)
+		}
+
+		if mode == ExactLookup {
+			return
+		}
+
+		key := mode.Key(name)
+		if existing, ok := names[key]; ok {
+			result = append(result, &CollisionError{Directory: directory.AbsolutePath(), Existing: existing, Name: name, Mode: mode})
+			return
+		}
+		names[key] = name
+	}
+
+	for _, file := range directory.Files() {
+		check(file.AbsolutePath())
+	}
+
+	for _, dir := range directory.Directories() {
+		check(dir.AbsolutePath())
+	}
+
+	for _, dir := range directory.Directories() {
+		result = append(result, ValidatePortable(dir, platforms)...)
+	}
+
+	return result
+}
+
+// portableLookupMode returns the lookup mode under which names collide on at least one of the platforms
+func portableLookupMode(platforms paths.Platform) LookupMode {
+	switch {
+	case platforms&paths.Darwin != 0:
+		return CaseInsensitiveLookup | NormalizedLookup
+
+	case platforms&paths.Windows != 0:
+		return CaseInsensitiveLookup
+	}
+
+	return ExactLookup
+}
@@ -0,0 +1,76 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package files
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should validate the portability of directories", func() {
+	var root Directory
+
+	BeforeEach(func() {
+		root = NewRootDirectory()
+		Expect(root.NewFile(paths.Of("assets/config.yml")).Write(bytes.NewBufferString("config"))).To(BeNil())
+	})
+
+	_ = It("should accept portable names", func() {
+		Expect(ValidatePortable(root, paths.AllPlatforms)).To(BeEmpty())
+	})
+
+	_ = It("should report invalid names with their path", func() {
+		Expect(root.NewFile(paths.Of("assets/nested/aux.txt")).Write(bytes.NewBufferString("aux"))).To(BeNil())
+
+		problems := ValidatePortable(root, paths.Windows)
+		Expect(problems).To(HaveLen(1))
+		Expect(errors.Is(problems[0], paths.ErrNotPortable)).To(BeTrue())
+		Expect(problems[0].Error()).To(ContainSubstring("assets/nested/aux.txt"))
+
+		Expect(ValidatePortable(root, paths.Linux)).To(BeEmpty())
+	})
+
+	_ = It("should report names differing only by case", func() {
+		Expect(root.NewFile(paths.Of("assets/Config.yml")).Write(bytes.NewBufferString("config"))).To(BeNil())
+
+		problems := ValidatePortable(root, paths.Windows)
+		Expect(problems).To(HaveLen(1))
+
+		var collision *CollisionError
+		Expect(errors.As(problems[0], &collision)).To(BeTrue())
+		Expect(collision.Mode).To(BeEquivalentTo(CaseInsensitiveLookup))
+
+		Expect(ValidatePortable(root, paths.Linux)).To(BeEmpty())
+	})
+
+	_ = It("should report names differing only by normalization on darwin", func() {
+		Expect(root.NewFile(paths.Of("assets/\u00e4.txt")).Write(bytes.NewBufferString("composed"))).To(BeNil())
+		Expect(root.NewFile(paths.Of("assets/a\u0308.txt")).Write(bytes.NewBufferString("decomposed"))).To(BeNil())
+
+		Expect(ValidatePortable(root, paths.Darwin)).To(HaveLen(1))
+		Expect(ValidatePortable(root, paths.Windows)).To(BeEmpty())
+	})
+})
@@ -22,6 +22,7 @@ package files
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -69,7 +70,8 @@ var _ = Describe("should handle files properly", func() {
 
 	_ = It("should return the correct paths", func() {
 		testFile := root.NewDirectory(paths.Of("usr")).NewDirectory(paths.Of("homeport")).NewDirectory(paths.Of("home")).NewFile(paths.Of("test.go"))
-		Expect(testFile.AbsolutePath().String()).To(BeEquivalentTo(filepath.FromSlash("/usr/homeport/home/test.go")))
+		Expect(testFile.AbsolutePath().String()).To(BeEquivalentTo("/usr/homeport/home/test.go"))
+		Expect(testFile.AbsolutePath().ToOS()).To(BeEquivalentTo(filepath.FromSlash("/usr/homeport/home/test.go")))
 	})
 
 	_ = It("should create nested files", func() {
@@ -224,3 +226,63 @@ func GetFilePermission(path string) os.FileMode {
 func IsOS(os string) bool {
 	return runtime.GOOS == os
 }
+
+var _ = Describe("should load lazy files on first access", func() {
+	_ = It("should call the loader once", func() {
+		calls := 0
+		file, e := NewLazyFile(NewRootDirectory(), paths.Of("lazy/file.txt"), func() ([]byte, error) {
+			calls++
+			return []byte("lazy"), nil
+		})
+		Expect(e).To(BeNil())
+		Expect(calls).To(BeEquivalentTo(0))
+
+		for i := 0; i < 2; i++ {
+			content := &bytes.Buffer{}
+			Expect(file.CopyContent(content)).To(BeNil())
+			Expect(content.String()).To(BeEquivalentTo("lazy"))
+		}
+		Expect(calls).To(BeEquivalentTo(1))
+
+		Expect(file.WriteFlagged(bytes.NewBufferString("!"), true)).To(BeNil())
+		content := &bytes.Buffer{}
+		Expect(file.CopyContent(content)).To(BeNil())
+		Expect(content.String()).To(BeEquivalentTo("lazy!"))
+	})
+
+	_ = It("should not call the loader once the file was overwritten", func() {
+		file, e := NewLazyFile(NewRootDirectory(), paths.Of("file.txt"), func() ([]byte, error) {
+			Fail("loader was called")
+			return nil, nil
+		})
+		Expect(e).To(BeNil())
+
+		Expect(file.Write(bytes.NewBufferString("written"))).To(BeNil())
+		content := &bytes.Buffer{}
+		Expect(file.CopyContent(content)).To(BeNil())
+		Expect(content.String()).To(BeEquivalentTo("written"))
+	})
+
+	_ = It("should know the size without copying the content", func() {
+		calls := 0
+		file, e := NewLazyFile(NewRootDirectory(), paths.Of("file.txt"), func() ([]byte, error) {
+			calls++
+			return []byte("lazy"), nil
+		})
+		Expect(e).To(BeNil())
+
+		Expect(fileSize(file)).To(BeEquivalentTo(4))
+		Expect(file.WriteFlagged(bytes.NewBufferString("!"), true)).To(BeNil())
+		Expect(fileSize(file)).To(BeEquivalentTo(5))
+		Expect(calls).To(BeEquivalentTo(1))
+	})
+
+	_ = It("should report errors of the loader", func() {
+		failure := errors.New("failure")
+		file, e := NewLazyFile(NewRootDirectory(), paths.Of("file.txt"), func() ([]byte, error) {
+			return nil, failure
+		})
+		Expect(e).To(BeNil())
+		Expect(errors.Is(file.CopyContent(&bytes.Buffer{}), failure)).To(BeTrue())
+	})
+})
@@ -0,0 +1,240 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// TreeOptions configures how WriteTree renders a directory
+//
+// MaxDepth limits the amount of levels printed below the directory, zero or less prints all levels.
+//
+// Sorted sorts the entries of each directory by name instead of printing directories before files in the
+// order they were created.
+//
+// ASCII uses plain ASCII characters instead of unicode box drawing characters for the tree lines.
+type TreeOptions struct {
+	ShowPermission bool
+	ShowSize       bool
+	ShowChecksum   bool
+	MaxDepth       int
+	Sorted         bool
+	ASCII          bool
+}
+
+// treeSymbols holds the characters used to draw the lines of the tree
+type treeSymbols struct {
+	branch string
+	last   string
+	pipe   string
+	space  string
+}
+
+var (
+	unicodeTreeSymbols = treeSymbols{branch: "├── ", last: "└── ", pipe: "│   ", space: "    "}
+	asciiTreeSymbols   = treeSymbols{branch: "|-- ", last: "`-- ", pipe: "|   ", space: "    "}
+)
+
+// treeEntry is either a file or a directory listed in the tree
+type treeEntry struct {
+	name      string
+	file      File
+	directory Directory
+}
+
+// treeWriter renders a directory into the writer line by line. The sizes of the directories are kept, so every
+// file is only counted once for all of its parent directories.
+type treeWriter struct {
+	writer      io.Writer
+	options     TreeOptions
+	symbols     treeSymbols
+	directories int
+	files       int
+	sizes       map[Directory]int64
+}
+
+// WriteTree writes the directory as a human readable tree into the writer, similar to the output of the tree command
+func WriteTree(writer io.Writer, directory Directory, options TreeOptions) error {
+	t := &treeWriter{writer: writer, options: options, symbols: unicodeTreeSymbols, sizes: map[Directory]int64{}}
+	if options.ASCII {
+		t.symbols = asciiTreeSymbols
+	}
+
+	name := directory.Name().String()
+	if directory.Parent() == nil || len(name) == 0 {
+		name = "."
+	}
+
+	columns, e := t.columns(treeEntry{name: name, directory: directory})
+	if e != nil {
+		return e
+	}
+
+	if _, err := fmt.Fprintln(writer, columns+name); err != nil {
+		return err
+	}
+
+	if err := t.writeDirectory(directory, "", 1); err != nil {
+		return err
+	}
+
+	_, e = fmt.Fprintf(writer, "\n%d %s, %d %s\n",
+		t.directories, plural(t.directories, "directory", "directories"),
+		t.files, plural(t.files, "file", "files"))
+	return e
+}
+
+// writeDirectory writes the entries of the directory with the given line prefix
+func (t *treeWriter) writeDirectory(directory Directory, prefix string, depth int) error {
+	if t.options.MaxDepth > 0 && depth > t.options.MaxDepth {
+		return nil
+	}
+
+	entries := make([]treeEntry, 0, len(directory.Directories())+len(directory.Files()))
+	for _, dir := range directory.Directories() {
+		entries = append(entries, treeEntry{name: dir.Name().String(), directory: dir})
+	}
+	for _, file := range directory.Files() {
+		entries = append(entries, treeEntry{name: file.Name().String(), file: file})
+	}
+
+	if t.options.Sorted {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].name < entries[j].name
+		})
+	}
+
+	for index, entry := range entries {
+		connector, indent := t.symbols.branch, t.symbols.pipe
+		if index == len(entries)-1 {
+			connector, indent = t.symbols.last, t.symbols.space
+		}
+
+		columns, e := t.columns(entry)
+		if e != nil {
+			return e
+		}
+
+		if _, err := fmt.Fprintln(t.writer, prefix+connector+columns+entry.name); err != nil {
+			return err
+		}
+
+		if entry.directory == nil {
+			t.files++
+			continue
+		}
+
+		t.directories++
+		if err := t.writeDirectory(entry.directory, prefix+indent, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// columns returns the optional mode, size and checksum columns of the entry or an empty string if none are enabled
+func (t *treeWriter) columns(entry treeEntry) (string, error) {
+	var values []string
+
+	if t.options.ShowPermission {
+		if entry.directory != nil {
+			values = append(values, (entry.directory.PermissionSet().Perm() | os.ModeDir).String())
+		} else {
+			values = append(values, entry.file.PermissionSet().String())
+		}
+	}
+
+	if t.options.ShowSize {
+		size, e := t.entrySize(entry)
+		if e != nil {
+			return "", e
+		}
+		values = append(values, fmt.Sprintf("%10d", size))
+	}
+
+	if t.options.ShowChecksum {
+		checksum := strings.Repeat(" ", 16)
+		if entry.file != nil {
+			hash := sha256.New()
+			if err := entry.file.CopyContent(hash); err != nil {
+				return "", err
+			}
+			checksum = hex.EncodeToString(hash.Sum(nil))[:16]
+		}
+		values = append(values, checksum)
+	}
+
+	if len(values) == 0 {
+		return "", nil
+	}
+	return "[" + strings.Join(values, " ") + "]  ", nil
+}
+
+// entrySize returns the size of a file or the accumulated size of all files found below a directory
+func (t *treeWriter) entrySize(entry treeEntry) (int64, error) {
+	if entry.file != nil {
+		return fileSize(entry.file)
+	}
+	return t.directorySize(entry.directory)
+}
+
+// directorySize returns the accumulated size of all files found below the directory, which is computed only once
+func (t *treeWriter) directorySize(directory Directory) (int64, error) {
+	if size, ok := t.sizes[directory]; ok {
+		return size, nil
+	}
+
+	var size int64
+	for _, file := range directory.Files() {
+		fileSize, e := fileSize(file)
+		if e != nil {
+			return 0, e
+		}
+		size += fileSize
+	}
+
+	for _, dir := range directory.Directories() {
+		dirSize, e := t.directorySize(dir)
+		if e != nil {
+			return 0, e
+		}
+		size += dirSize
+	}
+
+	t.sizes[directory] = size
+	return size, nil
+}
+
+// plural returns the singular or plural form based on the count
+func plural(count int, singular string, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,92 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package files
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should render directories as a tree", func() {
+
+	var (
+		root   Directory
+		output *strings.Builder
+	)
+
+	BeforeEach(func() {
+		root = NewRootDirectory()
+		output = &strings.Builder{}
+
+		Expect(root.NewFile(paths.Of("zeta.txt")).WithPermission(0644).Write(bytes.NewBufferString("zeta"))).To(BeNil())
+		Expect(root.NewDirectory(paths.Of("bin")).WithPermission(0755)).To(Not(BeNil()))
+		Expect(root.NewFile(paths.Of("bin/run.sh")).WithPermission(0755).Write(bytes.NewBufferString("#!/bin/sh"))).To(BeNil())
+		Expect(root.NewFile(paths.Of("alpha.txt")).WithPermission(0600).Write(bytes.NewBufferString("alpha"))).To(BeNil())
+	})
+
+	_ = It("should render the tree with unicode lines", func() {
+		Expect(WriteTree(output, root, TreeOptions{})).To(BeNil())
+		Expect(output.String()).To(BeEquivalentTo(strings.Join([]string{
+			".",
+			"├── bin",
+			"│   └── run.sh",
+			"├── zeta.txt",
+			"└── alpha.txt",
+			"",
+			"1 directory, 3 files",
+			"",
+		}, "\n")))
+	})
+
+	_ = It("should render sorted entries with ascii lines", func() {
+		Expect(WriteTree(output, root, TreeOptions{Sorted: true, ASCII: true})).To(BeNil())
+		Expect(output.String()).To(HavePrefix(strings.Join([]string{
+			".",
+			"|-- alpha.txt",
+			"|-- bin",
+			"|   `-- run.sh",
+			"`-- zeta.txt",
+		}, "\n")))
+	})
+
+	_ = It("should render mode and size columns", func() {
+		Expect(WriteTree(output, root, TreeOptions{Sorted: true, ShowPermission: true, ShowSize: true})).To(BeNil())
+		Expect(output.String()).To(ContainSubstring("├── [-rw-------          5]  alpha.txt\n"))
+		Expect(output.String()).To(ContainSubstring("├── [drwxr-xr-x          9]  bin\n"))
+		Expect(output.String()).To(ContainSubstring("│   └── [-rwxr-xr-x          9]  run.sh\n"))
+	})
+
+	_ = It("should render checksums of files", func() {
+		Expect(WriteTree(output, root, TreeOptions{Sorted: true, ShowChecksum: true})).To(BeNil())
+		Expect(output.String()).To(ContainSubstring("[8ed3f6ad685b959e]  alpha.txt"))
+	})
+
+	_ = It("should stop at the depth limit", func() {
+		Expect(WriteTree(output, root, TreeOptions{MaxDepth: 1})).To(BeNil())
+		Expect(output.String()).To(Not(ContainSubstring("run.sh")))
+		Expect(output.String()).To(ContainSubstring("1 directory, 2 files"))
+	})
+})
@@ -49,7 +49,8 @@ func WalkDirectoryTree(directory Directory, consumer func(d Directory)) {
 	}
 }
 
-// LoadFromDisk loads the content of the paths into the directory recursively
+// LoadFromDisk loads the content of the paths into the directory recursively.
+// A CollisionError is returned if two entries on disk map to the same name under the lookup mode of the directory.
 func LoadFromDisk(directory Directory, path string) (e error) {
 	return loadFromDisk(directory, path)
 }
@@ -72,6 +73,10 @@ func loadFromDisk(directory Directory, path string) (e error) {
 		}
 
 		for _, file := range directoryContent {
+			if err := checkCollision(directory, file.Name()); err != nil {
+				return err
+			}
+
 			if file.IsDir() {
 				if err := loadFromDisk(directory.NewDirectory(paths.Of(file.Name())).WithPermission(file.Mode()), filepath.Join(path, file.Name())); err != nil {
 					return err
@@ -102,19 +107,36 @@ func readFileInto(directory Directory, path string) (e error) {
 		return statError
 	}
 
-	return directory.NewFile(paths.Of(path).Drop()).WithPermission(info.Mode()).Write(bytes.NewBuffer(content))
+	return directory.NewFile(paths.Of(filepath.Base(path))).WithPermission(info.Mode()).Write(bytes.NewBuffer(content))
 }
 
 // WriteToDisk writes a directory to the given path
 // Overwriting or skipping an existing file based on the bool
 func WriteToDisk(directory Directory, path string, overwrite bool) (e error) {
+	return WriteToDiskWithOptions(directory, path, WriteOptions{Overwrite: overwrite})
+}
+
+// WriteToDiskWithOptions writes a directory to the given path, applying permissions and ownership
+// based on the write options.
+// Symbolic links in the path itself are resolved, everything below the path is created and opened relative to
+// its parent directory without following symbolic links, so a link planted in the target cannot redirect the
+// write. Writing fails with ErrSymbolicLink if a symbolic link is found where a file or directory should be
+// written.
+func WriteToDiskWithOptions(directory Directory, path string, options WriteOptions) (e error) {
+	path = filepath.Clean(path)
+
 	info, statError := os.Stat(path)
 	if statError != nil {
 		if !os.IsNotExist(statError) {
 			return statError
 		}
 
-		if err := os.MkdirAll(path, directory.PermissionSet()); err != nil {
+		mode, e := options.directoryMode(directory, path)
+		if e != nil {
+			return e
+		}
+
+		if err := os.MkdirAll(path, mode); err != nil {
 			return err
 		}
 	}
@@ -123,54 +145,67 @@ func WriteToDisk(directory Directory, path string, overwrite bool) (e error) {
 		return fmt.Errorf("provided path pointed to file %s", path)
 	}
 
+	if path, e = filepath.EvalSymlinks(path); e != nil {
+		return e
+	}
+
+	handle, e := openDirectoryHandle(path)
+	if e != nil {
+		return e
+	}
+
 	if directory.Parent() != nil { // If the directory is not a root directory, we want to create the directory
-		path = filepath.Join(path, directory.Name().String())
+		e = writeDirectoryToDisk(handle, directory, options)
+	} else {
+		e = writeDirectoryContentToDisk(handle, directory, options)
 	}
 
-	return writeDirectoryToDisk(directory, path, overwrite)
+	if err := handle.Close(); err != nil && e == nil {
+		return err
+	}
+	return e
 }
 
-func writeFileToDisk(file File, directoryPath string, overwrite bool) (e error) {
-	path := filepath.Join(directoryPath, file.Name().String())
-	var fileOnDisk *os.File
+func writeFileToDisk(parent dirHandle, file File, options WriteOptions) (e error) {
+	name := file.Name().String()
 
-	info, e := os.Stat(path)
-	if e != nil { // Checking if statistics for path cannot be found
-		if !os.IsNotExist(e) { // Checking if the path does exist but couldn't be accessed
-			return e
-		}
+	mode, e := options.fileMode(file, filepath.Join(parent.Name(), name))
+	if e != nil {
+		return e
+	}
 
-		if fileOnDisk, e = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, file.PermissionSet()); e == nil { // Creating the path given that it doesn't exist
-			if err := fileOnDisk.Close(); err != nil { // Close stream again
-				return err
-			}
-			if err := os.Chmod(path, file.PermissionSet()); err != nil { // Chmod the file
-				return err
-			}
-		} else {
-			return e
-		}
+	fileOnDisk, created, e := parent.file(name, mode, options.Overwrite)
+	if e != nil {
+		return e
 	}
 
-	if info != nil { // Checking if the path currently exists
-		if info.IsDir() { // Checking if the path is a directory
-			return fmt.Errorf("provided path pointed to directory %s", path)
-		}
+	if fileOnDisk == nil { // The file exists and should not be overwritten
+		return nil
+	}
 
-		if !overwrite { // Checking if the file should be overwritten given it exists
-			return nil
-		}
+	if e = writeFileContent(fileOnDisk, file, mode, created, options); e != nil {
+		_ = fileOnDisk.Close()
+		return e
+	}
 
-		if info.Mode() != file.PermissionSet() { // Update file permission if needed
-			if err := os.Chmod(path, file.PermissionSet()); err != nil {
-				return err
-			}
+	// Checking for errors while closing the disk file
+	return fileOnDisk.Close()
+}
+
+func writeFileContent(fileOnDisk *os.File, file File, mode os.FileMode, created bool, options WriteOptions) (e error) {
+	info, e := fileOnDisk.Stat()
+	if e != nil {
+		return e
+	}
+
+	if created || modeDiffers(info, mode) { // Chmod the file as the create mode is subject to the umask
+		if err := fileOnDisk.Chmod(mode); err != nil {
+			return err
 		}
 	}
 
-	if fileOnDisk, e = os.OpenFile(path, os.O_RDWR|os.O_TRUNC, file.PermissionSet()); e != nil {
-		// Check for errors after opening the file
-		return e
+	if err := fileOnDisk.Truncate(0); err != nil {
+		return err
 	}
 
 	if err := file.CopyContent(fileOnDisk); err != nil {
@@ -178,49 +213,58 @@ func writeFileToDisk(file File, directoryPath string, overwrite bool) (e error)
 		return err
 	}
 
-	if err := fileOnDisk.Close(); err != nil {
-		// Checking for errors while closing the disk file
-		return err
-	}
-	return nil
+	return options.chown(fileOnDisk)
 }
 
-func writeDirectoryToDisk(directory Directory, directoryPath string, overwrite bool) (e error) {
-	info, e := os.Stat(directoryPath)
-	permissionSet := directory.PermissionSet()
+func writeDirectoryToDisk(parent dirHandle, directory Directory, options WriteOptions) (e error) {
+	name := directory.Name().String()
 
+	permissionSet, e := options.directoryMode(directory, filepath.Join(parent.Name(), name))
 	if e != nil {
-		if !os.IsNotExist(e) {
-			return e
-		}
+		return e
+	}
 
-		if e := os.MkdirAll(directoryPath, permissionSet); e != nil {
-			return e
-		}
+	handle, created, e := parent.directory(name, permissionSet)
+	if e != nil {
+		return e
+	}
 
-		if info, e = os.Stat(directoryPath); e != nil {
-			return e
-		}
+	if e = applyDirectoryPermission(handle, permissionSet, created, options); e == nil {
+		e = writeDirectoryContentToDisk(handle, directory, options)
 	}
 
-	if info != nil && !info.IsDir() {
-		return fmt.Errorf("provided path pointed to file %s", directoryPath)
+	if err := handle.Close(); err != nil && e == nil {
+		return err
 	}
+	return e
+}
 
-	if overwrite && info.Mode() != permissionSet && directory.Parent() != nil { // Overwrite permissions if overwrite is enabled and folder is actual folder (not root)
-		if err := os.Chmod(directoryPath, permissionSet); err != nil {
+func applyDirectoryPermission(handle dirHandle, permissionSet os.FileMode, created bool, options WriteOptions) error {
+	info, e := handle.Stat()
+	if e != nil {
+		return e
+	}
+
+	// Apply permissions if overwrite is enabled, or if the folder was created and the options change its mode,
+	// otherwise the umask applies to created folders
+	if (options.Overwrite || created && options.changesDirectoryMode(permissionSet)) && modeDiffers(info, permissionSet) {
+		if err := handle.Chmod(permissionSet); err != nil {
 			return err
 		}
 	}
 
+	return options.chown(handle)
+}
+
+func writeDirectoryContentToDisk(handle dirHandle, directory Directory, options WriteOptions) error {
 	for _, dir := range directory.Directories() {
-		if err := writeDirectoryToDisk(dir, filepath.Join(directoryPath, dir.Name().String()), overwrite); err != nil {
+		if err := writeDirectoryToDisk(handle, dir, options); err != nil {
 			return err
 		}
 	}
 
 	for _, file := range directory.Files() {
-		if err := writeFileToDisk(file, directoryPath, overwrite); err != nil {
+		if err := writeFileToDisk(handle, file, options); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,71 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package files
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	// ErrSymbolicLink is returned when a symbolic link is found where WriteToDisk should write a file or directory
+	ErrSymbolicLink = errors.New("refusing to follow symbolic link")
+)
+
+// dirHandle is an opened directory on disk. Files and directories are created relative to it and are never
+// resolved through symbolic links.
+//
+// directory opens the sub directory with the name and creates it if it does not exist yet
+//
+// file opens the file with the name for writing and creates it if it does not exist yet.
+// If the file exists and overwrite is not set, a nil file is returned.
+type dirHandle interface {
+	Name() string
+	Stat() (os.FileInfo, error)
+	Chmod(mode os.FileMode) error
+	Chown(uid int, gid int) error
+	Close() error
+
+	directory(name string, mode os.FileMode) (handle dirHandle, created bool, e error)
+	file(name string, mode os.FileMode, overwrite bool) (file *os.File, created bool, e error)
+}
+
+// symbolicLinkError returns the error reported when a symbolic link was found at the path
+func symbolicLinkError(path string) error {
+	return fmt.Errorf("%w %s", ErrSymbolicLink, path)
+}
+
+// entryTypeError returns the error reported when the entry at the path is of an unexpected type
+func entryTypeError(path string, mode os.FileMode) error {
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return symbolicLinkError(path)
+
+	case mode.IsDir():
+		return fmt.Errorf("provided path pointed to directory %s", path)
+
+	case mode.IsRegular():
+		return fmt.Errorf("provided path pointed to file %s", path)
+	}
+
+	return fmt.Errorf("provided path pointed to %s entry %s", mode.Type().String(), path)
+}
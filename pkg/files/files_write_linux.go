@@ -0,0 +1,147 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package files
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	directoryFlags = unix.O_RDONLY | unix.O_DIRECTORY | unix.O_NOFOLLOW | unix.O_CLOEXEC
+	createFlags    = unix.O_WRONLY | unix.O_CREAT | unix.O_EXCL | unix.O_NOFOLLOW | unix.O_CLOEXEC
+	overwriteFlags = unix.O_WRONLY | unix.O_NOFOLLOW | unix.O_NONBLOCK | unix.O_CLOEXEC
+)
+
+// fdHandle is a directory handle based on an open file descriptor. All entries are opened using openat
+// with O_NOFOLLOW, so no component below the handle can be swapped for a symbolic link between checking
+// and writing it.
+type fdHandle struct {
+	*os.File
+}
+
+// openDirectoryHandle opens the directory at the path, which has to be resolved already, failing if the path
+// itself is a symbolic link
+func openDirectoryHandle(path string) (dirHandle, error) {
+	fd, e := unix.Open(path, directoryFlags, 0)
+	if e != nil {
+		return nil, openError(unix.AT_FDCWD, path, path, "open", e)
+	}
+
+	return &fdHandle{File: os.NewFile(uintptr(fd), path)}, nil
+}
+
+// directory opens the sub directory with the name and creates it if it does not exist yet
+func (h *fdHandle) directory(name string, mode os.FileMode) (dirHandle, bool, error) {
+	path := filepath.Join(h.Name(), name)
+	created := false
+
+	fd, e := unix.Openat(h.fd(), name, directoryFlags, 0)
+	if e == unix.ENOENT {
+		if e = unix.Mkdirat(h.fd(), name, uint32(mode.Perm())); e != nil && e != unix.EEXIST {
+			return nil, false, &os.PathError{Op: "mkdirat", Path: path, Err: e}
+		}
+
+		created = e == nil
+		fd, e = unix.Openat(h.fd(), name, directoryFlags, 0)
+	}
+
+	if e != nil {
+		return nil, false, openError(h.fd(), name, path, "openat", e)
+	}
+
+	return &fdHandle{File: os.NewFile(uintptr(fd), path)}, created, nil
+}
+
+// file opens the file with the name for writing and creates it if it does not exist yet
+func (h *fdHandle) file(name string, mode os.FileMode, overwrite bool) (*os.File, bool, error) {
+	path := filepath.Join(h.Name(), name)
+
+	fd, e := unix.Openat(h.fd(), name, createFlags, uint32(mode.Perm()))
+	if e == nil {
+		return os.NewFile(uintptr(fd), path), true, nil
+	}
+
+	if e != unix.EEXIST {
+		return nil, false, &os.PathError{Op: "openat", Path: path, Err: e}
+	}
+
+	if !overwrite { // Only check that the existing entry is no directory, it will not be written
+		var stat unix.Stat_t
+		if err := unix.Fstatat(h.fd(), name, &stat, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return nil, false, &os.PathError{Op: "fstatat", Path: path, Err: err}
+		}
+
+		if stat.Mode&unix.S_IFMT == unix.S_IFDIR {
+			return nil, false, entryTypeError(path, os.ModeDir)
+		}
+		return nil, false, nil
+	}
+
+	if fd, e = unix.Openat(h.fd(), name, overwriteFlags, 0); e != nil {
+		return nil, false, openError(h.fd(), name, path, "openat", e)
+	}
+
+	file := os.NewFile(uintptr(fd), path)
+	info, e := file.Stat()
+	if e == nil && !info.Mode().IsRegular() {
+		e = entryTypeError(path, info.Mode())
+	}
+
+	if e != nil {
+		_ = file.Close()
+		return nil, false, e
+	}
+
+	return file, false, nil
+}
+
+// fd returns the file descriptor of the directory
+func (h *fdHandle) fd() int {
+	return int(h.Fd())
+}
+
+// openError translates a failed open of the name relative to the directory file descriptor into a descriptive error
+func openError(dirfd int, name string, path string, op string, e error) error {
+	if e != unix.ELOOP && e != unix.ENOTDIR && e != unix.EISDIR {
+		return &os.PathError{Op: op, Path: path, Err: e}
+	}
+
+	var stat unix.Stat_t
+	if err := unix.Fstatat(dirfd, name, &stat, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return &os.PathError{Op: op, Path: path, Err: e}
+	}
+
+	switch stat.Mode & unix.S_IFMT {
+	case unix.S_IFLNK:
+		return entryTypeError(path, os.ModeSymlink)
+
+	case unix.S_IFDIR:
+		return entryTypeError(path, os.ModeDir)
+
+	case unix.S_IFREG:
+		return entryTypeError(path, 0)
+	}
+
+	return &os.PathError{Op: op, Path: path, Err: e}
+}
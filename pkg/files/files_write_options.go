@@ -0,0 +1,109 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package files
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	// specialBits contains the setuid, setgid and sticky bits
+	specialBits = os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+)
+
+var (
+	// ErrSpecialPermissionBits is returned when an entry with setuid, setgid or sticky bit should be written
+	// to disk without the write options allowing it
+	ErrSpecialPermissionBits = errors.New("refusing to write setuid, setgid or sticky bit")
+)
+
+// WriteOptions controls how WriteToDiskWithOptions writes a directory to disk
+//
+// Overwrite overwrites existing files and their permissions instead of skipping them.
+//
+// Mask is cleared from every permission set before it is applied, the same way a umask works. Without a Mask or
+// DirectoryMode, created directories are subject to the umask of the process.
+//
+// FileMode and DirectoryMode replace the permission set of each file and directory if they are not zero.
+// The Mask is applied to them as well.
+//
+// Chown changes the owner of every written entry to UID and GID. It only takes effect when running as root.
+//
+// AllowSpecialBits permits writing entries with setuid, setgid or sticky bit. Without it, writing such an
+// entry fails with ErrSpecialPermissionBits.
+type WriteOptions struct {
+	Overwrite bool
+
+	Mask          os.FileMode
+	FileMode      os.FileMode
+	DirectoryMode os.FileMode
+
+	Chown bool
+	UID   int
+	GID   int
+
+	AllowSpecialBits bool
+}
+
+// fileMode returns the permission set that is applied to the file at the path
+func (o WriteOptions) fileMode(file File, path string) (os.FileMode, error) {
+	return o.mode(file.PermissionSet(), o.FileMode, path)
+}
+
+// directoryMode returns the permission set that is applied to the directory at the path
+func (o WriteOptions) directoryMode(directory Directory, path string) (os.FileMode, error) {
+	return o.mode(directory.PermissionSet(), o.DirectoryMode, path)
+}
+
+// mode applies the override and mask to the permission set and checks it for special bits
+func (o WriteOptions) mode(set os.FileMode, override os.FileMode, path string) (os.FileMode, error) {
+	if override != 0 {
+		set = override
+	}
+
+	mode := set & (os.ModePerm | specialBits) &^ o.Mask
+	if !o.AllowSpecialBits && mode&specialBits != 0 {
+		return 0, fmt.Errorf("%w: %s would be written with %s", ErrSpecialPermissionBits, path, mode.String())
+	}
+
+	return mode, nil
+}
+
+// changesDirectoryMode returns if the options change the mode of directories, so the umask must not apply to it
+func (o WriteOptions) changesDirectoryMode(mode os.FileMode) bool {
+	return o.Mask != 0 || o.DirectoryMode != 0 || mode&specialBits != 0
+}
+
+// chown changes the owner of the written entry if requested and possible
+func (o WriteOptions) chown(entry interface{ Chown(uid int, gid int) error }) error {
+	if !o.Chown || os.Geteuid() != 0 {
+		return nil
+	}
+
+	return entry.Chown(o.UID, o.GID)
+}
+
+// modeDiffers returns if the permission bits of the file info differ from the mode
+func modeDiffers(info os.FileInfo, mode os.FileMode) bool {
+	return info.Mode()&(os.ModePerm|specialBits) != mode
+}
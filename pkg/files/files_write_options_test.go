@@ -0,0 +1,123 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build !windows
+// +build !windows
+
+package files
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should apply write options when writing to disk", func() {
+
+	var (
+		root      Directory
+		targetDir string
+	)
+
+	BeforeEach(func() {
+		root = NewRootDirectory()
+		Expect(root.NewDirectory(paths.Of("shared")).WithPermission(0777)).To(Not(BeNil()))
+		Expect(root.NewFile(paths.Of("shared/config.yml")).WithPermission(0666).Write(bytes.NewBufferString("key: value"))).To(BeNil())
+
+		var e error
+		targetDir, e = ioutil.TempDir("", "pgl-write-options")
+		Expect(e).To(BeNil())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(targetDir)).To(BeNil())
+	})
+
+	_ = It("should apply the mask to all entries", func() {
+		Expect(WriteToDiskWithOptions(root, targetDir, WriteOptions{Overwrite: true, Mask: 0022})).To(BeNil())
+
+		Expect(GetFilePermission(filepath.Join(targetDir, "shared")).Perm()).To(BeEquivalentTo(0755))
+		Expect(GetFilePermission(filepath.Join(targetDir, "shared", "config.yml")).Perm()).To(BeEquivalentTo(0644))
+	})
+
+	_ = It("should only bypass the umask for created directories if the options change their mode", func() {
+		umask := syscall.Umask(0027)
+		defer syscall.Umask(umask)
+
+		Expect(WriteToDisk(root, targetDir, false)).To(BeNil())
+		Expect(GetFilePermission(filepath.Join(targetDir, "shared")).Perm()).To(BeEquivalentTo(0750))
+
+		Expect(os.RemoveAll(filepath.Join(targetDir, "shared"))).To(BeNil())
+		Expect(WriteToDiskWithOptions(root, targetDir, WriteOptions{Mask: 0002})).To(BeNil())
+		Expect(GetFilePermission(filepath.Join(targetDir, "shared")).Perm()).To(BeEquivalentTo(0775))
+	})
+
+	_ = It("should apply explicit file and directory modes", func() {
+		Expect(WriteToDiskWithOptions(root, targetDir, WriteOptions{
+			Overwrite:     true,
+			FileMode:      0640,
+			DirectoryMode: 0750,
+		})).To(BeNil())
+
+		Expect(GetFilePermission(filepath.Join(targetDir, "shared")).Perm()).To(BeEquivalentTo(0750))
+		Expect(GetFilePermission(filepath.Join(targetDir, "shared", "config.yml")).Perm()).To(BeEquivalentTo(0640))
+	})
+
+	_ = It("should reject special permission bits unless allowed", func() {
+		root.File(paths.Of("shared/config.yml")).WithPermission(0755 | os.ModeSetuid)
+
+		e := WriteToDisk(root, targetDir, true)
+		Expect(errors.Is(e, ErrSpecialPermissionBits)).To(BeTrue())
+
+		Expect(WriteToDiskWithOptions(root, targetDir, WriteOptions{Overwrite: true, AllowSpecialBits: true})).To(BeNil())
+		Expect(GetFilePermission(filepath.Join(targetDir, "shared", "config.yml")) & os.ModeSetuid).To(Not(BeZero()))
+	})
+
+	_ = It("should reject sticky directories unless allowed", func() {
+		root.Directory(paths.Of("shared")).WithPermission(0777 | os.ModeSticky)
+
+		e := WriteToDiskWithOptions(root, targetDir, WriteOptions{Overwrite: true})
+		Expect(errors.Is(e, ErrSpecialPermissionBits)).To(BeTrue())
+	})
+
+	_ = It("should change the owner when running as root", func() {
+		if os.Geteuid() != 0 {
+			Skip("Requires root")
+			return
+		}
+
+		Expect(WriteToDiskWithOptions(root, targetDir, WriteOptions{Overwrite: true, Chown: true, UID: 4242, GID: 4343})).To(BeNil())
+
+		for _, path := range []string{filepath.Join(targetDir, "shared"), filepath.Join(targetDir, "shared", "config.yml")} {
+			info, e := os.Stat(path)
+			Expect(e).To(BeNil())
+			Expect(info.Sys().(*syscall.Stat_t).Uid).To(BeEquivalentTo(4242))
+			Expect(info.Sys().(*syscall.Stat_t).Gid).To(BeEquivalentTo(4343))
+		}
+	})
+})
@@ -0,0 +1,125 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build !linux
+// +build !linux
+
+package files
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// pathHandle is a path based directory handle for platforms without openat support in this package.
+// Every entry is checked using Lstat before it is opened, which refuses planted symbolic links but cannot
+// rule out a link that is swapped in between the check and the open.
+type pathHandle struct {
+	path string
+}
+
+// openDirectoryHandle opens the directory at the path, which has to be resolved already, failing if the path
+// itself is a symbolic link
+func openDirectoryHandle(path string) (dirHandle, error) {
+	info, e := os.Lstat(path)
+	if e != nil {
+		return nil, e
+	}
+
+	if !info.IsDir() {
+		return nil, entryTypeError(path, info.Mode())
+	}
+
+	return &pathHandle{path: path}, nil
+}
+
+// Name returns the path of the directory
+func (h *pathHandle) Name() string {
+	return h.path
+}
+
+// Stat returns the file info of the directory
+func (h *pathHandle) Stat() (os.FileInfo, error) {
+	return os.Lstat(h.path)
+}
+
+// Chmod changes the permission set of the directory
+func (h *pathHandle) Chmod(mode os.FileMode) error {
+	return os.Chmod(h.path, mode)
+}
+
+// Chown changes the owner of the directory
+func (h *pathHandle) Chown(uid int, gid int) error {
+	return os.Lchown(h.path, uid, gid)
+}
+
+// Close does nothing, as no resources are held by a path handle
+func (h *pathHandle) Close() error {
+	return nil
+}
+
+// directory opens the sub directory with the name and creates it if it does not exist yet
+func (h *pathHandle) directory(name string, mode os.FileMode) (dirHandle, bool, error) {
+	path := filepath.Join(h.path, name)
+
+	info, e := os.Lstat(path)
+	if e != nil {
+		if !os.IsNotExist(e) {
+			return nil, false, e
+		}
+
+		if err := os.Mkdir(path, mode.Perm()); err != nil {
+			return nil, false, err
+		}
+		return &pathHandle{path: path}, true, nil
+	}
+
+	if !info.IsDir() {
+		return nil, false, entryTypeError(path, info.Mode())
+	}
+
+	return &pathHandle{path: path}, false, nil
+}
+
+// file opens the file with the name for writing and creates it if it does not exist yet
+func (h *pathHandle) file(name string, mode os.FileMode, overwrite bool) (*os.File, bool, error) {
+	path := filepath.Join(h.path, name)
+
+	info, e := os.Lstat(path)
+	if e != nil {
+		if !os.IsNotExist(e) {
+			return nil, false, e
+		}
+
+		file, e := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm())
+		return file, e == nil, e
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil, false, entryTypeError(path, info.Mode())
+	}
+
+	if !overwrite {
+		return nil, false, nil
+	}
+
+	file, e := os.OpenFile(path, os.O_WRONLY, mode.Perm())
+	return file, false, e
+}
@@ -0,0 +1,147 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build !windows
+// +build !windows
+
+package files
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/pina-golada/pkg/files/paths"
+)
+
+var _ = Describe("should not follow symbolic links when writing to disk", func() {
+
+	var (
+		root       Directory
+		tempDir    string
+		targetDir  string
+		outsideDir string
+	)
+
+	BeforeEach(func() {
+		root = NewRootDirectory()
+		Expect(root.NewFile(paths.Of("config/app.yml")).WithPermission(0644).Write(bytes.NewBufferString("asset"))).To(BeNil())
+
+		var e error
+		tempDir, e = ioutil.TempDir("", "pgl-symlink")
+		Expect(e).To(BeNil())
+
+		targetDir = filepath.Join(tempDir, "target")
+		outsideDir = filepath.Join(tempDir, "outside")
+		Expect(os.Mkdir(targetDir, 0755)).To(BeNil())
+		Expect(os.Mkdir(outsideDir, 0755)).To(BeNil())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(BeNil())
+	})
+
+	_ = It("should refuse a link planted at a file", func() {
+		outsideFile := filepath.Join(outsideDir, "secret")
+		Expect(ioutil.WriteFile(outsideFile, []byte("secret"), 0600)).To(BeNil())
+
+		Expect(os.Mkdir(filepath.Join(targetDir, "config"), 0755)).To(BeNil())
+		Expect(os.Symlink(outsideFile, filepath.Join(targetDir, "config", "app.yml"))).To(BeNil())
+
+		e := WriteToDisk(root, targetDir, true)
+		Expect(errors.Is(e, ErrSymbolicLink)).To(BeTrue())
+
+		content, e := ioutil.ReadFile(outsideFile)
+		Expect(e).To(BeNil())
+		Expect(string(content)).To(BeEquivalentTo("secret"))
+		Expect(GetFilePermission(outsideFile).Perm()).To(BeEquivalentTo(0600))
+	})
+
+	_ = It("should refuse a dangling link planted at a file", func() {
+		outsideFile := filepath.Join(outsideDir, "created")
+
+		Expect(os.Mkdir(filepath.Join(targetDir, "config"), 0755)).To(BeNil())
+		Expect(os.Symlink(outsideFile, filepath.Join(targetDir, "config", "app.yml"))).To(BeNil())
+
+		e := WriteToDisk(root, targetDir, true)
+		Expect(errors.Is(e, ErrSymbolicLink)).To(BeTrue())
+
+		_, e = os.Lstat(outsideFile)
+		Expect(os.IsNotExist(e)).To(BeTrue())
+	})
+
+	_ = It("should refuse a link planted at a directory", func() {
+		Expect(os.Symlink(outsideDir, filepath.Join(targetDir, "config"))).To(BeNil())
+
+		e := WriteToDisk(root, targetDir, true)
+		Expect(errors.Is(e, ErrSymbolicLink)).To(BeTrue())
+
+		content, e := ioutil.ReadDir(outsideDir)
+		Expect(e).To(BeNil())
+		Expect(content).To(BeEmpty())
+	})
+
+	_ = It("should write through a link as the target directory", func() {
+		linkedTarget := filepath.Join(tempDir, "linked")
+		Expect(os.Symlink(targetDir, linkedTarget)).To(BeNil())
+
+		Expect(WriteToDisk(root, linkedTarget, true)).To(BeNil())
+
+		content, e := ioutil.ReadFile(filepath.Join(targetDir, "config", "app.yml"))
+		Expect(e).To(BeNil())
+		Expect(string(content)).To(BeEquivalentTo("asset"))
+	})
+
+	_ = It("should write through a link within the path of the target directory", func() {
+		linkedParent := filepath.Join(tempDir, "linked")
+		Expect(os.Symlink(tempDir, linkedParent)).To(BeNil())
+
+		Expect(WriteToDisk(root, filepath.Join(linkedParent, "target", "nested"), true)).To(BeNil())
+
+		content, e := ioutil.ReadFile(filepath.Join(targetDir, "nested", "config", "app.yml"))
+		Expect(e).To(BeNil())
+		Expect(string(content)).To(BeEquivalentTo("asset"))
+	})
+
+	_ = It("should leave a link planted at a file untouched without overwrite", func() {
+		Expect(os.Mkdir(filepath.Join(targetDir, "config"), 0755)).To(BeNil())
+		Expect(os.Symlink(filepath.Join(outsideDir, "created"), filepath.Join(targetDir, "config", "app.yml"))).To(BeNil())
+
+		Expect(WriteToDisk(root, targetDir, false)).To(BeNil())
+
+		_, e := os.Lstat(filepath.Join(outsideDir, "created"))
+		Expect(os.IsNotExist(e)).To(BeTrue())
+	})
+
+	_ = It("should still write and overwrite regular files", func() {
+		Expect(WriteToDisk(root, targetDir, true)).To(BeNil())
+		Expect(root.File(paths.Of("config/app.yml")).Write(bytes.NewBufferString("new"))).To(BeNil())
+		Expect(WriteToDisk(root, targetDir, true)).To(BeNil())
+
+		content, e := ioutil.ReadFile(filepath.Join(targetDir, "config", "app.yml"))
+		Expect(e).To(BeNil())
+		Expect(string(content)).To(BeEquivalentTo("new"))
+	})
+})
@@ -18,98 +18,168 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 // THE SOFTWARE.
 
-// Package paths hosts the interface used by pina-golada to parse and concat relative and absolute
+// Package paths hosts the type used by pina-golada to parse and concat relative and absolute
 // file paths. It wraps around the raw path string to provide easy management of complex paths.
+//
+// Paths always use the forward slash as separator, independent of the host operating system, so the
+// same asset tree has the same elements on every platform. FromOS and ToOS convert from and to host paths.
 package paths
 
 import (
-	"os"
+	"errors"
+	"fmt"
+	"path"
 	"path/filepath"
 	"strings"
 )
 
-// Path represents a relative paths to a file
+const (
+	// Separator is the canonical separator of path elements
+	Separator = "/"
+)
+
+var (
+	// ErrOutsideRoot is returned when ".." elements of a path climb above its root
+	ErrOutsideRoot = errors.New("path climbs above its root")
+
+	// ErrNotRelative is returned by Rel if the target is not located below the base path
+	ErrNotRelative = errors.New("path is not located below the base path")
+)
+
+// Path represents a relative path to a file. A Path is an immutable value: none of its methods modify the
+// receiver and no two paths share the slice holding their elements.
 //
-// ElementAt returns the element of the paths at the given index starting at 0
+// ElementAt returns the element of the path at the given index starting at 0
 // If index is out of range, the returned path is not valid
 //
-// Direct returns if the paths only has one element left
+// Direct returns if the path only has one element left
 //
 // Valid returns if the path is actually valid
 //
-// Size returns the amount of elements left in the paths
+// Size returns the amount of elements in the path
+//
+// Concat returns a new path with the elements of the other path appended
+//
+// Slice returns a copy of the path elements
+//
+// Pop splits the path into its first element and the remaining elements
+//
+// Drop splits the path into the leading elements and its last element
+//
+// Parent returns the path without its last element
+//
+// Base returns the last element of the path
+//
+// Ext returns the file name extension of the last element
 //
-// Clone will create new instance of the paths
+// HasPrefix returns if the path starts with all elements of another path
 //
-// Concat will concat the two paths together
+// TrimPrefix returns the path without the elements of a leading path
 //
-// Slice returns the slice of path entries
+// Join resolves slash separated elements relative to the path
 //
-// Pop will pop the first element in the paths
+// Match reports whether the path matches a shell pattern
 //
-// Drop drops the last element in the path
-type Path interface {
-	ElementAt(index int) Path
-	Direct() bool
-	Valid() bool
-	Size() int
+// Paths created by Of, Parse, FromOS or Join never contain "." or ".." elements, as they are resolved
+// lexically and rejected if they climb above the root.
+type Path struct {
+	elements []string
+}
 
-	Clone() Path
-	Equals(path Path) bool
+// ElementAt returns the element of the path at the given index starting at 0
+// If index is out of range, return an invalid path
+func (p Path) ElementAt(index int) Path {
+	if index < 0 || index >= p.Size() {
+		return Path{}
+	}
+	return uncheckedOfString(p.elements[index])
+}
 
-	Concat(other Path) Path
-	Slice() []string
-	String() string
+// Direct returns if the path only has one element
+func (p Path) Direct() bool {
+	return p.Size() == 1
+}
 
-	Pop() Path
-	Drop() Path
+// Valid returns if the path is actually valid
+func (p Path) Valid() bool {
+	return p.Size() > 0
 }
 
-// MemoryPath implements the Path interface
-type MemoryPath struct {
-	path []string
+// Size returns the amount of elements in the path
+func (p Path) Size() int {
+	return len(p.elements)
 }
 
-// ElementAt returns the element of the paths at the given index starting at 0
-// If index is out of range, return an empty string
-func (m *MemoryPath) ElementAt(index int) Path {
-	if index < 0 || index >= m.Size() {
-		return &MemoryPath{}
+// Equals returns if the path is equal to another
+func (p Path) Equals(other Path) bool {
+	if p.Size() != other.Size() {
+		return false
+	}
+
+	for index, element := range p.elements {
+		if other.elements[index] != element {
+			return false
+		}
 	}
-	return uncheckedOfString(m.path[index])
+
+	return true
 }
 
-// Direct returns if the paths only has one element left
-func (m *MemoryPath) Direct() bool {
-	return m.Size() == 1
+// Concat returns a new path consisting of the elements of this path followed by the elements of the other path
+func (p Path) Concat(other Path) Path {
+	elements := make([]string, 0, p.Size()+other.Size())
+	elements = append(elements, p.elements...)
+	return Path{elements: append(elements, other.elements...)}
 }
 
-// Valid returns if the path is actually valid
-func (m *MemoryPath) Valid() bool {
-	return m.Size() > 0
+// Slice returns a copy of the path elements
+func (p Path) Slice() []string {
+	return copyElements(p.elements)
 }
 
-// Size returns the size of the paths
-func (m *MemoryPath) Size() int {
-	return len(m.path)
+// Pop splits the path into its first element and the remaining elements.
+// Both returned paths are invalid if the path is empty
+func (p Path) Pop() (first Path, rest Path) {
+	if p.Size() == 0 {
+		return Path{}, Path{}
+	}
+	return uncheckedOfString(p.elements[0]), Path{elements: copyElements(p.elements[1:])}
 }
 
-// Clone will create new instance of the paths
-func (m *MemoryPath) Clone() Path {
-	return &MemoryPath{
-		path: m.path,
+// Drop splits the path into the leading elements and its last element.
+// Both returned paths are invalid if the path is empty
+func (p Path) Drop() (rest Path, last Path) {
+	if p.Size() == 0 {
+		return Path{}, Path{}
 	}
+	return Path{elements: copyElements(p.elements[:p.Size()-1])}, uncheckedOfString(p.elements[p.Size()-1])
 }
 
-// Equals returns if the path is equal to another
-func (m *MemoryPath) Equals(path Path) bool {
-	if m.Size() != path.Size() {
+// Parent returns the path without its last element. The parent of a path with one element is an invalid path
+func (p Path) Parent() Path {
+	parent, _ := p.Drop()
+	return parent
+}
+
+// Base returns the last element of the path or an empty string if the path is empty
+func (p Path) Base() string {
+	_, last := p.Drop()
+	return last.String()
+}
+
+// Ext returns the file name extension of the last element, e.g. ".yml", or an empty string if there is none
+func (p Path) Ext() string {
+	return path.Ext(p.Base())
+}
+
+// HasPrefix returns if the path starts with all elements of the prefix path
+func (p Path) HasPrefix(prefix Path) bool {
+	if prefix.Size() > p.Size() {
 		return false
 	}
 
-	otherSlice := path.Slice()
-	for index, pathEntry := range m.Slice() {
-		if otherSlice[index] != pathEntry {
+	for index, element := range prefix.elements {
+		if p.elements[index] != element {
 			return false
 		}
 	}
@@ -117,61 +187,109 @@ func (m *MemoryPath) Equals(path Path) bool {
 	return true
 }
 
-// Concat will concat the two paths together
-func (m *MemoryPath) Concat(other Path) Path {
-	return &MemoryPath{
-		path: append(m.path, other.Slice()...),
+// TrimPrefix returns the path without the elements of the leading prefix path.
+// If the path does not start with the prefix, it is returned unchanged
+func (p Path) TrimPrefix(prefix Path) Path {
+	if !p.HasPrefix(prefix) {
+		return p
+	}
+	return Path{elements: copyElements(p.elements[prefix.Size():])}
+}
+
+// Join resolves the slash separated elements relative to the path. The returned path is invalid
+// if ".." elements climb above the root of the path
+func (p Path) Join(elements ...string) Path {
+	resolved, e := resolve(p.elements, strings.Split(strings.Join(elements, Separator), Separator))
+	if e != nil {
+		return Path{}
 	}
+	return Path{elements: resolved}
+}
+
+// Match reports whether the path matches the shell pattern. The pattern syntax is the one of path.Match,
+// so a * never matches a separator
+func (p Path) Match(pattern string) (bool, error) {
+	return path.Match(pattern, p.String())
+}
+
+// String returns the path in its canonical form, separated by forward slashes
+func (p Path) String() string {
+	return strings.Join(p.elements, Separator)
+}
+
+// ToOS returns the path using the path separator of the host operating system
+func (p Path) ToOS() string {
+	return filepath.FromSlash(p.String())
 }
 
-// Slice returns the slice of path entries
-func (m *MemoryPath) Slice() []string {
-	return m.path
+// Of creates a path off of a forward slash separated string. Other characters, like the backslash, are
+// never treated as separator, use FromOS to convert a path of the host operating system.
+// The returned path is invalid if the string climbs above its root, use Parse to get a descriptive error.
+func Of(value string) Path {
+	result, _ := Parse(value)
+	return result
 }
 
-// Pop will pop the first element in the paths
-func (m *MemoryPath) Pop() Path {
-	if m.Size() > 0 {
-		firstElement := m.path[0]
-		m.path = m.path[1:]
-		return uncheckedOfString(firstElement)
+// Parse creates a path off of a forward slash separated string. "." elements are dropped and ".." elements
+// remove the element in front of them. An error is returned if a ".." element would climb above the root.
+func Parse(value string) (Path, error) {
+	elements, e := resolve(nil, strings.Split(value, Separator))
+	if e != nil {
+		return Path{}, fmt.Errorf("%w: %s", e, value)
 	}
-	return &MemoryPath{}
+	return Path{elements: elements}, nil
 }
 
-// Drop will drop the last element in the path
-func (m *MemoryPath) Drop() Path {
-	if m.Size() > 0 {
-		lastElement := m.path[m.Size()-1]
-		m.path = m.path[:m.Size()-1]
-		return uncheckedOfString(lastElement)
+// Rel returns the path of target relative to base. An error is returned if the target is not located below
+// the base, as a path cannot climb above its root. Rel of two equal paths returns an invalid, empty path
+func Rel(base Path, target Path) (Path, error) {
+	if !target.HasPrefix(base) {
+		return Path{}, fmt.Errorf("%w: %s is not below %s", ErrNotRelative, target.String(), base.String())
 	}
-	return &MemoryPath{}
+	return target.TrimPrefix(base), nil
 }
 
-// Returns the paths as a string
-func (m *MemoryPath) String() string {
-	return strings.Join(m.path, GetPathSeparator())
+// FromOS creates a path off of a string that uses the path separator of the host operating system
+func FromOS(value string) Path {
+	return Of(filepath.ToSlash(value))
 }
 
-// Of creates a paths off of a string
-func Of(path string) *MemoryPath {
-	cleansedPath := strings.Trim(filepath.Clean(filepath.FromSlash(path)), GetPathSeparator())
-	return &MemoryPath{
-		path: strings.Split(cleansedPath, GetPathSeparator()),
+// resolve lexically applies the additional elements to the elements, dropping empty and "." elements.
+// The empty first element of a root path is kept and cannot be removed by a ".." element
+func resolve(elements []string, additions []string) ([]string, error) {
+	result := copyElements(elements)
+	for _, element := range additions {
+		switch element {
+		case "", ".":
+			continue
+
+		case "..":
+			if len(result) == 0 || (len(result) == 1 && len(result[0]) == 0) {
+				return nil, ErrOutsideRoot
+			}
+			result = result[:len(result)-1]
+
+		default:
+			result = append(result, element)
+		}
 	}
+
+	return result, nil
 }
 
 // uncheckedOfString creates a path with one entry
-func uncheckedOfString(path string) *MemoryPath {
-	return &MemoryPath{
-		path: []string{path},
+func uncheckedOfString(element string) Path {
+	return Path{
+		elements: []string{element},
 	}
 }
 
-// GetPathSeparator returns the paths separator of the current os
-func GetPathSeparator() string {
-	return string(os.PathSeparator)
+// copyElements returns a copy of the elements that does not share its backing array
+func copyElements(elements []string) []string {
+	if len(elements) == 0 {
+		return nil
+	}
+	return append(make([]string, 0, len(elements)), elements...)
 }
 
 // RootPath returns a path with one single empty string entry
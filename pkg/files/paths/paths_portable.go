@@ -0,0 +1,249 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package paths
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Platform is a set of target operating systems whose file name rules an element has to follow.
+// Platforms can be combined, e.g. Linux | Windows.
+type Platform int
+
+const (
+	// Linux only forbids NUL characters and names longer than 255 bytes
+	Linux Platform = 1 << iota
+
+	// Windows forbids reserved device names, a set of special characters, trailing dots and spaces as well as
+	// names longer than 255 UTF-16 code units. Names are compared case-insensitively.
+	Windows
+
+	// Darwin forbids NUL characters, invalid UTF-8 and names longer than 255 bytes. Names are compared
+	// case-insensitively after unicode normalization.
+	Darwin
+
+	// AllPlatforms combines all known platforms
+	AllPlatforms = Linux | Windows | Darwin
+)
+
+const (
+	// maxNameLength is the maximum length of a single name on all platforms, in bytes or UTF-16 code units
+	maxNameLength = 255
+
+	// windowsForbiddenCharacters may not be part of a name on windows
+	windowsForbiddenCharacters = `<>:"/\|?*`
+)
+
+var (
+	// ErrNotPortable is matched by every NameError using errors.Is
+	ErrNotPortable = errors.New("name is not portable")
+
+	// platformNames maps the platforms to their names as used by GOOS
+	platformNames = []struct {
+		platform Platform
+		name     string
+	}{
+		{Linux, "linux"},
+		{Windows, "windows"},
+		{Darwin, "darwin"},
+	}
+
+	// windowsReservedNames are the device names windows reserves, regardless of the extension
+	windowsReservedNames = map[string]bool{
+		"CON": true, "PRN": true, "AUX": true, "NUL": true, "CONIN$": true, "CONOUT$": true,
+		"COM0": true, "COM1": true, "COM2": true, "COM3": true, "COM4": true,
+		"COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+		"COM¹": true, "COM²": true, "COM³": true,
+		"LPT0": true, "LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true,
+		"LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+		"LPT¹": true, "LPT²": true, "LPT³": true,
+	}
+)
+
+// ParsePlatforms parses a list of platform names separated by commas or plus signs, e.g. "linux,windows"
+// or "linux+windows". The name "all" selects all platforms.
+func ParsePlatforms(value string) (Platform, error) {
+	names := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '+' })
+	if len(names) == 0 {
+		return 0, fmt.Errorf("no platform found in %q", value)
+	}
+
+	var result Platform
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "all" {
+			result |= AllPlatforms
+			continue
+		}
+
+		found := false
+		for _, entry := range platformNames {
+			if entry.name == name {
+				result |= entry.platform
+				found = true
+			}
+		}
+
+		if !found {
+			return 0, fmt.Errorf("unknown platform %q, expected one of linux, windows, darwin or all", name)
+		}
+	}
+
+	return result, nil
+}
+
+// String returns the comma separated names of the platforms
+func (p Platform) String() string {
+	var names []string
+	for _, entry := range platformNames {
+		if p&entry.platform != 0 {
+			names = append(names, entry.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// NameError is returned when a name cannot be created on one of the target platforms
+type NameError struct {
+	Path     Path
+	Name     string
+	Platform Platform
+	Reason   string
+}
+
+// Error returns the description of the violated rule
+func (n *NameError) Error() string {
+	if n.Path.Valid() {
+		return fmt.Sprintf("name %q of %q is not portable to %s: %s", n.Name, n.Path.String(),
+			n.Platform.String(), n.Reason)
+	}
+	return fmt.Sprintf("name %q is not portable to %s: %s", n.Name, n.Platform.String(), n.Reason)
+}
+
+// Is returns if the target is ErrNotPortable
+func (n *NameError) Is(target error) bool {
+	return target == ErrNotPortable
+}
+
+// ValidateName checks the single name against the rules of all platforms. The first violated rule is
+// returned as a NameError.
+func ValidateName(name string, platforms Platform) error {
+	for _, entry := range platformNames {
+		if platforms&entry.platform == 0 {
+			continue
+		}
+
+		if reason := violation(name, entry.platform); len(reason) > 0 {
+			return &NameError{Name: name, Platform: entry.platform, Reason: reason}
+		}
+	}
+
+	return nil
+}
+
+// ValidatePortable checks every element of the path against the rules of all platforms
+func (p Path) ValidatePortable(platforms Platform) error {
+	for _, element := range p.elements {
+		if len(element) == 0 { // The root element
+			continue
+		}
+
+		if e := ValidateName(element, platforms); e != nil {
+			nameError := e.(*NameError)
+			nameError.Path = p
+			return nameError
+		}
+	}
+
+	return nil
+}
+
+// violation returns the reason why the name is not valid on the platform or an empty string if it is valid
+func violation(name string, platform Platform) string {
+	switch {
+	case len(name) == 0:
+		return "name is empty"
+
+	case name == "." || name == "..":
+		return "name is reserved for directory navigation"
+
+	case strings.ContainsRune(name, 0):
+		return "name contains a NUL character"
+	}
+
+	switch platform {
+	case Linux:
+		if len(name) > maxNameLength {
+			return fmt.Sprintf("name is longer than %d bytes", maxNameLength)
+		}
+
+	case Darwin:
+		if !utf8.ValidString(name) {
+			return "name is not valid UTF-8"
+		}
+		if len(name) > maxNameLength {
+			return fmt.Sprintf("name is longer than %d bytes", maxNameLength)
+		}
+
+	case Windows:
+		return windowsViolation(name)
+	}
+
+	return ""
+}
+
+// windowsViolation returns the reason why the name is not valid on windows or an empty string if it is valid
+func windowsViolation(name string) string {
+	if !utf8.ValidString(name) {
+		return "name is not valid UTF-8"
+	}
+
+	for _, r := range name {
+		if r < 0x20 {
+			return fmt.Sprintf("name contains the control character %U", r)
+		}
+		if strings.ContainsRune(windowsForbiddenCharacters, r) {
+			return fmt.Sprintf("name contains the forbidden character %q", r)
+		}
+	}
+
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return "name ends with a dot or space"
+	}
+
+	stem := name
+	if index := strings.Index(stem, "."); index >= 0 {
+		stem = stem[:index]
+	}
+	if windowsReservedNames[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		return fmt.Sprintf("%s is a reserved device name", stem)
+	}
+
+	if length := len(utf16.Encode([]rune(name))); length > maxNameLength {
+		return fmt.Sprintf("name is longer than %d UTF-16 code units", maxNameLength)
+	}
+
+	return ""
+}
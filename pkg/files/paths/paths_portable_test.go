@@ -0,0 +1,97 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package paths
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("should validate portable names", func() {
+	notPortable := func(name string, platforms Platform) bool {
+		return errors.Is(ValidateName(name, platforms), ErrNotPortable)
+	}
+
+	_ = It("should parse platform lists", func() {
+		platforms, e := ParsePlatforms("linux, Windows")
+		Expect(e).To(BeNil())
+		Expect(platforms).To(BeEquivalentTo(Linux | Windows))
+
+		platforms, e = ParsePlatforms("darwin+windows")
+		Expect(e).To(BeNil())
+		Expect(platforms.String()).To(BeEquivalentTo("windows,darwin"))
+
+		platforms, e = ParsePlatforms("all")
+		Expect(e).To(BeNil())
+		Expect(platforms).To(BeEquivalentTo(AllPlatforms))
+
+		_, e = ParsePlatforms("plan9")
+		Expect(e).ToNot(BeNil())
+
+		_, e = ParsePlatforms("")
+		Expect(e).ToNot(BeNil())
+	})
+
+	_ = It("should reject reserved device names on windows", func() {
+		Expect(notPortable("aux.txt", Windows)).To(BeTrue())
+		Expect(notPortable("CON", Windows)).To(BeTrue())
+		Expect(notPortable("com1.tar.gz", Windows)).To(BeTrue())
+		Expect(notPortable("auxiliary.txt", Windows)).To(BeFalse())
+		Expect(notPortable("aux.txt", Linux|Darwin)).To(BeFalse())
+	})
+
+	_ = It("should reject forbidden characters", func() {
+		Expect(notPortable("a:b.txt", Windows)).To(BeTrue())
+		Expect(notPortable(`a\b.txt`, Windows)).To(BeTrue())
+		Expect(notPortable("a\tb.txt", Windows)).To(BeTrue())
+		Expect(notPortable("a:b.txt", Linux|Darwin)).To(BeFalse())
+		Expect(notPortable("a\x00b", Linux)).To(BeTrue())
+		Expect(notPortable("\xff", Darwin)).To(BeTrue())
+	})
+
+	_ = It("should reject trailing dots and spaces on windows", func() {
+		Expect(notPortable("name.", Windows)).To(BeTrue())
+		Expect(notPortable("name ", Windows)).To(BeTrue())
+		Expect(notPortable(".hidden", Windows)).To(BeFalse())
+	})
+
+	_ = It("should reject names that are too long", func() {
+		Expect(notPortable(strings.Repeat("a", 255), AllPlatforms)).To(BeFalse())
+		Expect(notPortable(strings.Repeat("a", 256), Linux)).To(BeTrue())
+		Expect(notPortable(strings.Repeat("ä", 128), Linux)).To(BeTrue())
+		Expect(notPortable(strings.Repeat("ä", 128), Windows)).To(BeFalse())
+	})
+
+	_ = It("should report the offending element of a path", func() {
+		e := Of("assets/aux.txt/config.yml").ValidatePortable(Windows)
+
+		var nameError *NameError
+		Expect(errors.As(e, &nameError)).To(BeTrue())
+		Expect(nameError.Name).To(BeEquivalentTo("aux.txt"))
+		Expect(nameError.Platform).To(BeEquivalentTo(Windows))
+		Expect(e.Error()).To(ContainSubstring("assets/aux.txt/config.yml"))
+
+		Expect(Of("assets/config.yml").ValidatePortable(AllPlatforms)).To(BeNil())
+	})
+})
@@ -21,7 +21,13 @@
 package paths
 
 import (
+	"errors"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/quick"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -32,13 +38,200 @@ func TestPaths(t *testing.T) {
 	RunSpecs(t, "pgl pkg files paths")
 }
 
+// elements is a list of random, valid path elements used for property based tests
+type elements []string
+
+// Generate creates a random list of path elements
+func (elements) Generate(r *rand.Rand, size int) reflect.Value {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_. äöü"
+
+	result := make(elements, 1+r.Intn(8))
+	for index := range result {
+		element := make([]rune, 1+r.Intn(12))
+		for i := range element {
+			element[i] = []rune(alphabet)[r.Intn(len([]rune(alphabet)))]
+		}
+
+		result[index] = strings.Trim(string(element), ". ") + "x" // Avoid "." and ".." elements
+	}
+
+	return reflect.ValueOf(result)
+}
+
+// path returns the path consisting of the elements
+func (e elements) path() Path {
+	return Of(strings.Join(e, Separator))
+}
+
 var _ = Describe("should parse paths correctly", func() {
 	_ = It("should pop correctly", func() {
-		path := Of("/usr/homeport/home/test.go")
-		Expect(path.Pop().String()).To(BeEquivalentTo("usr"))
-		Expect(path.Pop().String()).To(BeEquivalentTo("homeport"))
-		Expect(path.Pop().String()).To(BeEquivalentTo("home"))
-		Expect(path.Pop().String()).To(BeEquivalentTo("test.go"))
-		Expect(path.Pop().String()).To(BeEquivalentTo(""))
+		first, rest := Of("/usr/homeport/home/test.go").Pop()
+		Expect(first.String()).To(BeEquivalentTo("usr"))
+		Expect(rest.String()).To(BeEquivalentTo("homeport/home/test.go"))
+
+		first, rest = Of("test.go").Pop()
+		Expect(first.String()).To(BeEquivalentTo("test.go"))
+		Expect(rest.Valid()).To(BeFalse())
+
+		first, rest = rest.Pop()
+		Expect(first.Valid()).To(BeFalse())
+		Expect(rest.Valid()).To(BeFalse())
+	})
+
+	_ = It("should drop correctly", func() {
+		rest, last := Of("/usr/homeport/home/test.go").Drop()
+		Expect(rest.String()).To(BeEquivalentTo("usr/homeport/home"))
+		Expect(last.String()).To(BeEquivalentTo("test.go"))
+	})
+
+	_ = It("should use forward slashes independent of the host", func() {
+		Expect(Of("usr/homeport").Size()).To(BeEquivalentTo(2))
+		Expect(Of(`usr\homeport`).Size()).To(BeEquivalentTo(1))
+		Expect(Of("usr/homeport").String()).To(BeEquivalentTo("usr/homeport"))
+		Expect(Of("usr/homeport").ToOS()).To(BeEquivalentTo(filepath.Join("usr", "homeport")))
+		Expect(FromOS(filepath.Join("usr", "homeport")).Equals(Of("usr/homeport"))).To(BeTrue())
+	})
+})
+
+var _ = Describe("should behave like an immutable value", func() {
+	check := func(property interface{}) {
+		Expect(quick.Check(property, &quick.Config{MaxCount: 500})).To(Succeed())
+	}
+
+	_ = It("should not let concatenations from the same base corrupt each other", func() {
+		check(func(base elements, a elements, b elements) bool {
+			basePath := base.path()
+			for basePath.Size() < 64 { // Grow the base so appending may reuse spare capacity
+				basePath = basePath.Concat(base.path())
+			}
+
+			withA := basePath.Concat(a.path())
+			withB := basePath.Concat(b.path())
+
+			return withA.Equals(Of(basePath.String()+Separator+a.path().String())) &&
+				withB.Equals(Of(basePath.String()+Separator+b.path().String()))
+		})
+	})
+
+	_ = It("should not modify the receiver when popping or dropping", func() {
+		check(func(e elements) bool {
+			path := e.path()
+			original := path.String()
+
+			first, rest := path.Pop()
+			leading, last := path.Drop()
+
+			return path.String() == original &&
+				first.Concat(rest).Equals(path) &&
+				leading.Concat(last).Equals(path)
+		})
+	})
+
+	_ = It("should not share the slice with callers", func() {
+		check(func(e elements) bool {
+			path := e.path()
+			original := path.String()
+
+			slice := path.Slice()
+			slice[0] = "modified"
+
+			_, rest := path.Pop()
+			if rest.Valid() {
+				restSlice := rest.Slice()
+				restSlice[0] = "modified"
+			}
+
+			return path.String() == original
+		})
+	})
+
+	_ = It("should round trip through its string representations", func() {
+		check(func(e elements) bool {
+			path := e.path()
+			return Of(path.String()).Equals(path) &&
+				FromOS(path.ToOS()).Equals(path) &&
+				path.Size() == len(e)
+		})
+	})
+})
+
+var _ = Describe("should resolve paths lexically", func() {
+	_ = It("should resolve dot elements", func() {
+		Expect(Of("usr/./homeport/../test.go").String()).To(BeEquivalentTo("usr/test.go"))
+		Expect(Of("./usr//homeport/").String()).To(BeEquivalentTo("usr/homeport"))
+		Expect(Of("usr/..").Valid()).To(BeFalse())
+	})
+
+	_ = It("should reject paths climbing above their root", func() {
+		_, e := Parse("../etc/passwd")
+		Expect(errors.Is(e, ErrOutsideRoot)).To(BeTrue())
+
+		_, e = Parse("/usr/../../etc/passwd")
+		Expect(errors.Is(e, ErrOutsideRoot)).To(BeTrue())
+
+		Expect(Of("usr/../../etc").Valid()).To(BeFalse())
+		Expect(Of("usr").Join("../..").Valid()).To(BeFalse())
+		Expect(RootPath().Join("..").Valid()).To(BeFalse())
+	})
+
+	_ = It("should return parent, base and extension", func() {
+		path := Of("usr/homeport/config.tar.gz")
+		Expect(path.Parent().String()).To(BeEquivalentTo("usr/homeport"))
+		Expect(path.Base()).To(BeEquivalentTo("config.tar.gz"))
+		Expect(path.Ext()).To(BeEquivalentTo(".gz"))
+
+		Expect(Of("usr").Parent().Valid()).To(BeFalse())
+		Expect(Of("usr").Ext()).To(BeEmpty())
+		Expect(Path{}.Base()).To(BeEmpty())
+	})
+
+	_ = It("should handle prefixes element wise", func() {
+		path := Of("usr/homeport/test.go")
+		Expect(path.HasPrefix(Of("usr/homeport"))).To(BeTrue())
+		Expect(path.HasPrefix(Of("usr/home"))).To(BeFalse())
+		Expect(path.HasPrefix(Path{})).To(BeTrue())
+
+		Expect(path.TrimPrefix(Of("usr")).String()).To(BeEquivalentTo("homeport/test.go"))
+		Expect(path.TrimPrefix(Of("usr/home")).Equals(path)).To(BeTrue())
+	})
+
+	_ = It("should compute relative paths", func() {
+		rel, e := Rel(Of("usr/homeport"), Of("usr/homeport/home/test.go"))
+		Expect(e).To(BeNil())
+		Expect(rel.String()).To(BeEquivalentTo("home/test.go"))
+
+		_, e = Rel(Of("usr/homeport"), Of("usr/other/test.go"))
+		Expect(errors.Is(e, ErrNotRelative)).To(BeTrue())
+	})
+
+	_ = It("should join elements", func() {
+		Expect(Of("usr").Join("homeport", "home/test.go").String()).To(BeEquivalentTo("usr/homeport/home/test.go"))
+		Expect(Of("usr/homeport").Join("../other", "./test.go").String()).To(BeEquivalentTo("usr/other/test.go"))
+		Expect(Of("usr").Join().Equals(Of("usr"))).To(BeTrue())
+	})
+
+	_ = It("should match shell patterns", func() {
+		matched, e := Of("assets/config.yml").Match("assets/*.yml")
+		Expect(e).To(BeNil())
+		Expect(matched).To(BeTrue())
+
+		matched, e = Of("assets/nested/config.yml").Match("assets/*.yml")
+		Expect(e).To(BeNil())
+		Expect(matched).To(BeFalse())
+
+		_, e = Of("assets").Match("[")
+		Expect(e).ToNot(BeNil())
+	})
+
+	_ = It("should never contain dot elements after joining", func() {
+		Expect(quick.Check(func(base elements, joined elements) bool {
+			path := base.path().Join(strings.Join(joined, "/../"), ".")
+			for _, element := range path.Slice() {
+				if element == "." || element == ".." {
+					return false
+				}
+			}
+			return path.HasPrefix(base.path())
+		}, &quick.Config{MaxCount: 500})).To(Succeed())
 	})
 })
@@ -24,6 +24,8 @@ package test
 
 import (
 	"bytes"
+	"errors"
+	"github.com/homeport/pina-golada/pkg/compressor"
 	"github.com/homeport/pina-golada/pkg/files"
 	"github.com/homeport/pina-golada/pkg/files/paths"
 	. "github.com/onsi/ginkgo"
@@ -74,6 +76,19 @@ var _ = Describe("Should have generated correctly", func() {
 		Expect(buffer.String()).To(BeEquivalentTo("Hello there. General Kenobi."))
 	})
 
+	_ = It("should look up files in the lookup mode of the annotation", func() {
+		dir, e := Provider.GetCaseInsensitiveFolderAsset()
+		Expect(e).To(Not(HaveOccurred()))
+		Expect(files.LookupModeOf(dir)).To(BeEquivalentTo(files.CaseInsensitiveLookup))
+
+		content := dir.File(paths.Of("CONTENT.md"))
+		Expect(content).To(Not(BeNil()))
+
+		buffer := &bytes.Buffer{}
+		Expect(content.CopyContent(buffer)).To(Not(HaveOccurred()))
+		Expect(buffer.String()).To(BeEquivalentTo("Hello there. General Kenobi."))
+	})
+
 	_ = It("should write files correctly", func() {
 		dir, e := Provider.GetFileAsset()
 		Expect(e).To(Not(HaveOccurred()))
@@ -109,6 +124,90 @@ var _ = Describe("Should have generated correctly", func() {
 })
 
 // NotWindows returns if the system is not windows
+var _ = Describe("Should have encrypted assets", func() {
+	_ = It("should decrypt assets with the injected key", func() {
+		dir, e := Provider.GetEncryptedFileAsset()
+		Expect(e).To(Not(HaveOccurred()))
+
+		buffer := &bytes.Buffer{}
+		Expect(dir.File(paths.Of("file.txt")).CopyContent(buffer)).To(Not(HaveOccurred()))
+		Expect(buffer.String()).To(BeEquivalentTo("All Your Base Are Belong to Us"))
+	})
+
+	_ = It("should report a missing key", func() {
+		original := compressor.DefaultKeyProvider
+		defer func() { compressor.DefaultKeyProvider = original }()
+
+		compressor.DefaultKeyProvider = func() ([]byte, error) { return compressor.DecodeKey("") }
+
+		_, e := Provider.GetEncryptedFileAsset()
+		Expect(errors.Is(e, compressor.ErrNoKey)).To(BeTrue())
+	})
+})
+
+var _ = Describe("Should have signed assets", func() {
+	_ = It("should verify signed assets", func() {
+		dir, e := SignedProvider.GetSignedFolderAsset()
+		Expect(e).To(Not(HaveOccurred()))
+		Expect(dir.File(paths.Of("content.md"))).To(Not(BeNil()))
+	})
+
+	_ = It("should verify signed and encrypted assets", func() {
+		dir, e := SignedProvider.GetSignedEncryptedFileAsset()
+		Expect(e).To(Not(HaveOccurred()))
+
+		buffer := &bytes.Buffer{}
+		Expect(dir.File(paths.Of("file.txt")).CopyContent(buffer)).To(Not(HaveOccurred()))
+		Expect(buffer.String()).To(BeEquivalentTo("All Your Base Are Belong to Us"))
+	})
+})
+
+var _ = Describe("Should check the integrity of assets", func() {
+	_ = It("should report edited assets", func() {
+		_, e := (&PGLAssets{}).requestAssetByPath("GetFileAsset", "tar", "1f8b", "", "")
+		Expect(errors.Is(e, compressor.ErrIntegrity)).To(BeTrue())
+		Expect(e.Error()).To(ContainSubstring("Assets#GetFileAsset"))
+
+		_, e = (&PGLAssets{}).requestAssetByPath("GetFileAsset", "tar", "1f8", "", "")
+		Expect(errors.Is(e, compressor.ErrIntegrity)).To(BeTrue())
+	})
+})
+
+var _ = Describe("Should have assets sharing a dictionary", func() {
+	_ = It("should decompress assets using the shared dictionary", func() {
+		dir, e := DictionaryProvider.GetDictionaryFileAsset()
+		Expect(e).To(Not(HaveOccurred()))
+
+		buffer := &bytes.Buffer{}
+		Expect(dir.File(paths.Of("file.txt")).CopyContent(buffer)).To(Not(HaveOccurred()))
+		Expect(buffer.String()).To(BeEquivalentTo("All Your Base Are Belong to Us"))
+
+		dir, e = DictionaryProvider.GetDictionaryFolderAsset()
+		Expect(e).To(Not(HaveOccurred()))
+		Expect(dir.File(paths.Of("content.md"))).To(Not(BeNil()))
+	})
+})
+
+var _ = Describe("Should have assets read from archives", func() {
+	_ = It("should decompress embedded archives", func() {
+		dir, e := ArchiveProvider.GetArchiveAsset()
+		Expect(e).To(Not(HaveOccurred()))
+		Expect(dir.File(paths.Of("README.md"))).To(Not(BeNil()))
+
+		buffer := &bytes.Buffer{}
+		Expect(dir.File(paths.Of("dist/index.html")).CopyContent(buffer)).To(Not(HaveOccurred()))
+		Expect(buffer.String()).To(ContainSubstring("<title>pina-golada</title>"))
+	})
+
+	_ = It("should decompress re-rooted archives", func() {
+		dir, e := ArchiveProvider.GetRerootedArchiveAsset()
+		Expect(e).To(Not(HaveOccurred()))
+		Expect(dir.File(paths.Of("index.html"))).To(Not(BeNil()))
+		Expect(dir.File(paths.Of("static/style.css"))).To(Not(BeNil()))
+		Expect(dir.File(paths.Of("README.md"))).To(BeNil())
+	})
+})
+
 func NotWindows() bool {
 	return !IsOS("windows")
 }
@@ -41,6 +41,48 @@ type Assets interface {
 
 	// @pgl(asset=assets/folder&compressor=tar)
 	GetFolderAsset() (dir files.Directory, e error)
+
+	// @pgl(asset=assets/file.txt&compressor=tar&encrypted=true)
+	GetEncryptedFileAsset() (dir files.Directory, e error)
+
+	// @pgl(asset=assets/folder&compressor=tar&lookup=insensitive)
+	GetCaseInsensitiveFolderAsset() (dir files.Directory, e error)
+}
+
+var SignedProvider SignedAssets
+
+// SignedAssets is the test injector variable of signed assets
+// @pgl(injector=SignedProvider&signingkeyenv=PINA_GOLADA_TEST_SIGNING_KEY)
+type SignedAssets interface {
+	// @pgl(asset=assets/folder&compressor=zip)
+	GetSignedFolderAsset() (dir files.Directory, e error)
+
+	// @pgl(asset=assets/file.txt&compressor=tar&encrypted=true)
+	GetSignedEncryptedFileAsset() (dir files.Directory, e error)
+}
+
+var DictionaryProvider DictionaryAssets
+
+// DictionaryAssets is the test injector variable of assets sharing a dictionary
+// @pgl(injector=DictionaryProvider&shareddictionary=true)
+type DictionaryAssets interface {
+	// @pgl(asset=assets/file.txt&compressor=dictionary)
+	GetDictionaryFileAsset() (dir files.Directory, e error)
+
+	// @pgl(asset=assets/folder&compressor=dictionary)
+	GetDictionaryFolderAsset() (dir files.Directory, e error)
+}
+
+var ArchiveProvider ArchiveAssets
+
+// ArchiveAssets is the test injector variable of assets read from archives
+// @pgl(injector=ArchiveProvider)
+type ArchiveAssets interface {
+	// @pgl(asset=../../../assets/tests/tar-archives/web-ui.tar.gz&source=archive)
+	GetArchiveAsset() (dir files.Directory, e error)
+
+	// @pgl(asset=../../../assets/tests/tar-archives/web-ui.tar.gz&source=archive&root=dist&compressor=zip)
+	GetRerootedArchiveAsset() (dir files.Directory, e error)
 }
 
 // IsOS returns if the current os equals the string
@@ -36,6 +36,12 @@ import (
 	"testing"
 )
 
+// encryptionKey is the key of the encrypted test assets
+const encryptionKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+// signingKey is the ed25519 seed that signs the signed test assets
+const signingKey = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+
 func TestCompileIntegrationTest(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "pgl integration compile")
@@ -48,7 +54,11 @@ var _ = SynchronizedBeforeSuite(func() []byte {
 	Expect(os.Chmod("assets/folder" , 0723)).To(Not(HaveOccurred()))
 	Expect(os.Chmod("assets/folder/content.md" , 0722)).To(Not(HaveOccurred()))
 
+	Expect(os.Setenv(builder.DefaultKeyEnvironment, encryptionKey)).To(Not(HaveOccurred()))
+	Expect(os.Setenv("PINA_GOLADA_TEST_SIGNING_KEY", signingKey)).To(Not(HaveOccurred()))
 	cmd.Generate(".", annotation.NewPropertyParser(), newLogger())
+	Expect(os.Unsetenv(builder.DefaultKeyEnvironment)).To(Not(HaveOccurred()))
+	Expect(os.Unsetenv("PINA_GOLADA_TEST_SIGNING_KEY")).To(Not(HaveOccurred()))
 
 	Expect(os.Chdir("..")).To(Not(HaveOccurred())) // Return to the original directory
 	return make([]byte, 0)
@@ -73,7 +83,9 @@ var _ = Describe("should compile provided unit test", func() {
 	_ = It("should run the unit-tests in the compiled integration test", func() {
 		Expect(os.Chdir(".test")).To(Not(HaveOccurred())) // Change into the hidden test directory to target it
 
-		command := exec.Command("go", "test", "./...", "--", "count=1")
+		command := exec.Command("go", "test",
+			"-ldflags", "-X github.com/homeport/pina-golada/pkg/compressor.EncryptionKey="+encryptionKey,
+			"./...", "--", "count=1")
 		command.Stdout = writer
 		command.Stderr = os.Stderr
 		if err := command.Run(); err != nil {